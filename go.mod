@@ -8,7 +8,9 @@ require (
 	github.com/spf13/cobra v1.8.1
 	github.com/tink-crypto/tink-go/v2 v2.4.0
 	golang.org/x/crypto v0.35.0
+	golang.org/x/term v0.29.0
 	google.golang.org/protobuf v1.36.5
+	gopkg.in/yaml.v3 v3.0.1
 )
 
 require (
@@ -39,5 +41,4 @@ require (
 	golang.org/x/sys v0.30.0 // indirect
 	golang.org/x/text v0.22.0 // indirect
 	gopkg.in/ini.v1 v1.67.0 // indirect
-	gopkg.in/yaml.v3 v3.0.1 // indirect
 )
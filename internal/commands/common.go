@@ -3,6 +3,8 @@ package commands
 import (
 	"fmt"
 
+	"github.com/spf13/cobra"
+
 	"github.com/idelchi/gocry/internal/config"
 	"github.com/idelchi/gogen/pkg/cobraext"
 )
@@ -21,8 +23,67 @@ func setFileAndValidate(cfg *config.Config, args []string) error {
 		return fmt.Errorf("validating configuration: %w", err)
 	}
 
-	if cfg.Key.String == "" && cfg.Key.File == "" {
-		return fmt.Errorf("%w: missing key: specify either --key or --key-file", config.ErrUsage)
+	return requireKey(cfg)
+}
+
+// setFilesAndValidate extends setFileAndValidate to commands accepting several files,
+// which must then be written to files rather than stdout.
+func setFilesAndValidate(cfg *config.Config, args []string) error {
+	cfg.Files = args
+
+	if err := setFileAndValidate(cfg, args); err != nil {
+		return err
+	}
+
+	output := cfg.Output
+
+	switch {
+	case output.InPlace && (output.Dir != "" || output.Suffix != ""):
+		return fmt.Errorf("%w: --in-place is mutually exclusive with --output-dir and --suffix", config.ErrUsage)
+	case !output.ToFiles() && (len(args) > 1 || output.Recursive):
+		return fmt.Errorf("%w: several files require --in-place, --output-dir or --suffix", config.ErrUsage)
+	}
+
+	return nil
+}
+
+// addOutputFlags adds the flags for writing results to files.
+func addOutputFlags(cmd *cobra.Command) {
+	cmd.Flags().Bool("in-place", false, "Replace each file with its result")
+	cmd.Flags().String("output-dir", "", "Directory to write the results to, keeping relative paths")
+	cmd.Flags().String("suffix", "", "Suffix appended to encrypted file names and removed from decrypted ones, e.g. .enc")
+	cmd.Flags().Bool("recursive", false, "Process the files within directories")
+}
+
+// requireKey ensures that exactly one of the key options has been set.
+func requireKey(cfg *config.Config) error {
+	key := cfg.Key
+
+	set := 0
+
+	for _, isSet := range []bool{
+		key.String != "",
+		key.File != "",
+		key.Keyring != "",
+		key.Passphrase != "",
+		key.Prompt,
+		len(key.Recipients) > 0,
+		key.Identity != "",
+	} {
+		if isSet {
+			set++
+		}
+	}
+
+	switch {
+	case set == 0:
+		return fmt.Errorf(
+			"%w: missing key: specify either --key, --key-file, --keyring, --passphrase, --passphrase-prompt, "+
+				"--recipient (encrypt) or --identity (decrypt)",
+			config.ErrUsage,
+		)
+	case set > 1:
+		return fmt.Errorf("%w: key options are mutually exclusive", config.ErrUsage)
 	}
 
 	return nil
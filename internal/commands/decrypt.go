@@ -11,20 +11,24 @@ import (
 // NewDecryptCommand creates a new cobra command for the decrypt operation.
 func NewDecryptCommand(cfg *config.Config) *cobra.Command {
 	cmd := &cobra.Command{
-		Use:     "decrypt file",
+		Use:     "decrypt file...",
 		Aliases: []string{"dec"},
 		Short:   "Decrypt files",
-		Long:    "Decrypt a file using the specified key. Output is printed to stdout.",
-		Args:    cobra.ExactArgs(1),
+		Long: "Decrypt a file using the specified key. Output is printed to stdout.\n" +
+			"With --in-place, --output-dir or --suffix, several files, globs and (with --recursive) directories\n" +
+			"are processed in parallel and written to files instead.",
+		Args: cobra.MinimumNArgs(1),
 		PreRunE: func(_ *cobra.Command, args []string) error {
 			cfg.Operation = encrypt.Decrypt
 
-			return setFileAndValidate(cfg, args)
+			return setFilesAndValidate(cfg, args)
 		},
 		RunE: func(_ *cobra.Command, _ []string) error {
 			return logic.Run(cfg)
 		},
 	}
 
+	addOutputFlags(cmd)
+
 	return cmd
 }
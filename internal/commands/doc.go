@@ -3,6 +3,14 @@
 // It implements commands for:
 //   - encryption
 //   - decryption
+//   - re-encryption under a new key
+//   - key generation
+//   - running commands with decrypted secrets
+//   - editing encrypted files
+//   - inspecting envelopes without a key
+//   - converting encrypted files for git diffs
+//   - setting up the git integration
+//   - merging line-mode files in git
 //
 // The package handles command-line parsing, configuration validation,
 // and environment variable binding through cobra and viper.
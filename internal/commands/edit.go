@@ -0,0 +1,44 @@
+package commands
+
+import (
+	"fmt"
+
+	"github.com/spf13/cobra"
+
+	"github.com/idelchi/gocry/internal/config"
+	"github.com/idelchi/gocry/internal/encrypt"
+	"github.com/idelchi/gocry/internal/logic"
+	"github.com/idelchi/gogen/pkg/cobraext"
+)
+
+// NewEditCommand creates a new cobra command for editing an encrypted file.
+func NewEditCommand(cfg *config.Config) *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "edit file",
+		Short: "Edit an encrypted file in $EDITOR",
+		Long: "Decrypt a file into a private temporary file (on tmpfs when available), open it in $EDITOR\n" +
+			"and re-encrypt it in place when the editor exits. Whole files are re-encrypted the way they were\n" +
+			"encrypted, and unchanged files are left as they are. The temporary file is shredded in any case.",
+		Args: cobra.ExactArgs(1),
+		PreRunE: func(_ *cobra.Command, args []string) error {
+			cfg.Operation = encrypt.Decrypt
+			cfg.File = args[0]
+
+			// The editor needs the terminal, so the file is never read from stdin
+			if err := cobraext.Validate(cfg, cfg); err != nil {
+				return fmt.Errorf("validating configuration: %w", err)
+			}
+
+			return requireKey(cfg)
+		},
+		RunE: func(_ *cobra.Command, _ []string) error {
+			return logic.Edit(cfg)
+		},
+	}
+
+	cmd.Flags().BoolVar(&cfg.Deterministic, "deterministic", true, "Encrypt new content deterministically (AES-SIV)")
+	cmd.Flags().Bool("chunked", false, "Encrypt new whole files in deterministic segments")
+	cmd.Flags().Bool("bind-path", false, "Bind new content to the file path")
+
+	return cmd
+}
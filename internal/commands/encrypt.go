@@ -11,15 +11,17 @@ import (
 // NewEncryptCommand creates a new cobra command for the encrypt operation.
 func NewEncryptCommand(cfg *config.Config) *cobra.Command {
 	cmd := &cobra.Command{
-		Use:     "encrypt file",
+		Use:     "encrypt file...",
 		Aliases: []string{"enc"},
 		Short:   "Encrypt files",
-		Long:    "Encrypt a file using the specified key. Output is printed to stdout.",
-		Args:    cobra.ExactArgs(1),
+		Long: "Encrypt a file using the specified key. Output is printed to stdout.\n" +
+			"With --in-place, --output-dir or --suffix, several files, globs and (with --recursive) directories\n" +
+			"are processed in parallel and written to files instead.",
+		Args: cobra.MinimumNArgs(1),
 		PreRunE: func(_ *cobra.Command, args []string) error {
 			cfg.Operation = encrypt.Encrypt
 
-			return setFileAndValidate(cfg, args)
+			return setFilesAndValidate(cfg, args)
 		},
 		RunE: func(_ *cobra.Command, _ []string) error {
 			return logic.Run(cfg)
@@ -27,6 +29,15 @@ func NewEncryptCommand(cfg *config.Config) *cobra.Command {
 	}
 
 	cmd.Flags().BoolVar(&cfg.Deterministic, "deterministic", true, "Enable deterministic encryption (AES-SIV)")
+	cmd.Flags().Bool("chunked", false, "Encrypt whole files in deterministic segments with bounded memory")
+	cmd.Flags().StringSlice("select", nil, "Path of the values to encrypt in structured modes (repeatable)")
+	cmd.Flags().String("key-regex", "", "Regular expression for the keys of the values to encrypt in structured modes")
+	cmd.Flags().StringSlice("plaintext", nil, "Key to leave unencrypted in dotenv mode (repeatable)")
+	cmd.Flags().Bool("values", false, "Encrypt only the value of key-value lines in line mode")
+	cmd.Flags().Bool("bind-path", false, "Bind the ciphertext to the file path, so that it only decrypts as that file")
+	cmd.Flags().StringSlice("recipient", nil, "X25519 public key to encrypt for (repeatable, randomized only)")
+
+	addOutputFlags(cmd)
 
 	return cmd
 }
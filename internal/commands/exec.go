@@ -0,0 +1,50 @@
+package commands
+
+import (
+	"fmt"
+
+	"github.com/spf13/cobra"
+
+	"github.com/idelchi/gocry/internal/config"
+	"github.com/idelchi/gocry/internal/encrypt"
+	"github.com/idelchi/gocry/internal/logic"
+	"github.com/idelchi/gogen/pkg/cobraext"
+)
+
+// NewExecCommand creates a new cobra command running a command with decrypted secrets in its environment.
+func NewExecCommand(cfg *config.Config) *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "exec file -- command [args...]",
+		Short: "Run a command with decrypted secrets as environment variables",
+		Long: "Decrypt a dotenv file in memory and run a command with its variables added to the environment.\n" +
+			"The plaintext is never written to disk. Signals are forwarded to the command, and its exit code is kept.",
+		Args: cobra.MinimumNArgs(2), //nolint:mnd // the file and the command
+		PreRunE: func(_ *cobra.Command, args []string) error {
+			cfg.Operation = encrypt.Decrypt
+			cfg.File = args[0]
+			cfg.Command = args[1:]
+
+			if cfg.Command[0] == "--" {
+				cfg.Command = cfg.Command[1:]
+			}
+
+			if len(cfg.Command) == 0 {
+				return fmt.Errorf("%w: missing command to run", config.ErrUsage)
+			}
+
+			if err := cobraext.Validate(cfg, cfg); err != nil {
+				return fmt.Errorf("validating configuration: %w", err)
+			}
+
+			return requireKey(cfg)
+		},
+		RunE: func(_ *cobra.Command, _ []string) error {
+			return logic.Exec(cfg)
+		},
+	}
+
+	// Flags after the file belong to the command, with or without a separating `--`
+	cmd.Flags().SetInterspersed(false)
+
+	return cmd
+}
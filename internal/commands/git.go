@@ -0,0 +1,196 @@
+package commands
+
+import (
+	"fmt"
+
+	"github.com/spf13/cobra"
+
+	"github.com/idelchi/gocry/internal/config"
+	"github.com/idelchi/gocry/internal/encrypt"
+	"github.com/idelchi/gocry/internal/logic"
+	"github.com/idelchi/gogen/pkg/cobraext"
+)
+
+// NewGitCommand creates a new cobra command grouping the git integration commands.
+func NewGitCommand(cfg *config.Config) *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "git command",
+		Short: "Set up the git integration",
+		Long:  "Configure git to encrypt, decrypt, diff and merge files through gocry filters and drivers.",
+		Args:  cobra.NoArgs,
+	}
+
+	cmd.AddCommand(
+		NewGitInstallCommand(cfg),
+		NewGitUninstallCommand(cfg),
+		NewGitFilterProcessCommand(cfg),
+		NewGitCheckCommand(cfg),
+		NewGitMergeCommand(cfg),
+	)
+
+	return cmd
+}
+
+// NewGitInstallCommand creates a new cobra command configuring the git integration for the current repository.
+func NewGitInstallCommand(cfg *config.Config) *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "install",
+		Short: "Configure the filters and drivers in the current repository",
+		Long: "Write the encrypt:line and encrypt:file filters and diff drivers, and the encrypt:line merge driver,\n" +
+			"to the local git configuration, using the key file or keyring given with the global options,\n" +
+			"and mark the filters as required.\n" +
+			"With --line-pattern and --file-pattern, the patterns are added to .gitattributes as well.\n" +
+			"With --bind-path, the filters bind the files they encrypt to their path in the repository.\n" +
+			"Running it again updates the configuration.",
+		Args: cobra.NoArgs,
+		PreRunE: func(_ *cobra.Command, _ []string) error {
+			cfg.Operation = encrypt.Encrypt
+
+			if err := cobraext.Validate(cfg, &cfg.Key); err != nil {
+				return fmt.Errorf("validating configuration: %w", err)
+			}
+
+			key := cfg.Key
+
+			if key.File == "" && key.Keyring == "" {
+				return fmt.Errorf("%w: missing key: specify either --key-file or --keyring", config.ErrUsage)
+			}
+
+			if key.String != "" || key.Passphrase != "" || key.Prompt || len(key.Recipients) > 0 || key.Identity != "" {
+				return fmt.Errorf("%w: only --key-file or --keyring can be written to the git configuration", config.ErrUsage)
+			}
+
+			return requireKey(cfg)
+		},
+		RunE: func(_ *cobra.Command, _ []string) error {
+			return logic.GitInstall(cfg)
+		},
+	}
+
+	cmd.Flags().BoolVar(&cfg.Deterministic, "deterministic", true, "Encrypt deterministically, keeping blobs stable")
+	cmd.Flags().Bool("bind-path", false, "Bind the files the filters encrypt to their path in the repository")
+	cmd.Flags().String("program", "gocry", "The gocry executable the filters and drivers run")
+	cmd.Flags().StringSlice("line-pattern", nil, "Pattern to add to .gitattributes for line mode (repeatable)")
+	cmd.Flags().StringSlice("file-pattern", nil, "Pattern to add to .gitattributes for whole files (repeatable)")
+
+	return cmd
+}
+
+// NewGitUninstallCommand creates a new cobra command removing the git integration from the current repository.
+func NewGitUninstallCommand(cfg *config.Config) *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "uninstall",
+		Short: "Remove the filters and drivers from the current repository",
+		Long: "Remove the encrypt:line and encrypt:file filters and drivers from the local git configuration,\n" +
+			"together with the .gitattributes lines using them.",
+		Args: cobra.NoArgs,
+		PreRunE: func(_ *cobra.Command, _ []string) error {
+			if err := cobraext.Validate(cfg); err != nil {
+				return fmt.Errorf("validating configuration: %w", err)
+			}
+
+			return nil
+		},
+		RunE: func(_ *cobra.Command, _ []string) error {
+			return logic.GitUninstall(cfg)
+		},
+	}
+
+	return cmd
+}
+
+// NewGitFilterProcessCommand creates a new cobra command running a long-running filter process for git.
+func NewGitFilterProcessCommand(cfg *config.Config) *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "filter-process",
+		Short: "Serve git as a long-running filter process",
+		Long: "Serve the clean and smudge requests of git over the long-running filter process protocol,\n" +
+			"set as filter.<driver>.process by git install. A single process loads the key once and filters\n" +
+			"every file of a checkout or commit, decrypting delayed files in parallel.",
+		Args: cobra.NoArgs,
+		PreRunE: func(_ *cobra.Command, _ []string) error {
+			cfg.Operation = encrypt.Encrypt
+
+			if err := cobraext.Validate(cfg, &cfg.Key); err != nil {
+				return fmt.Errorf("validating configuration: %w", err)
+			}
+
+			return requireKey(cfg)
+		},
+		RunE: func(_ *cobra.Command, _ []string) error {
+			return logic.FilterProcess(cfg)
+		},
+	}
+
+	cmd.Flags().BoolVar(&cfg.Deterministic, "deterministic", true, "Encrypt deterministically, keeping blobs stable")
+	cmd.Flags().Bool("bind-path", false, "Bind the files cleaned to their path in the repository")
+
+	return cmd
+}
+
+// NewGitCheckCommand creates a new cobra command verifying that filtered files are encrypted in the index.
+func NewGitCheckCommand(cfg *config.Config) *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "check",
+		Short: "Verify that filtered files are committed encrypted",
+		Long: "Verify that the staged files using the encrypt:file or encrypt:line filters are encrypted in the index,\n" +
+			"as a pre-commit hook or CI step. Whole files must hold a valid envelope, and line-mode files must not\n" +
+			"carry lines still marked for encryption. Offending files are reported and the command fails.\n" +
+			"No key is needed.",
+		Args: cobra.NoArgs,
+		PreRunE: func(_ *cobra.Command, _ []string) error {
+			if err := cobraext.Validate(cfg); err != nil {
+				return fmt.Errorf("validating configuration: %w", err)
+			}
+
+			return nil
+		},
+		RunE: func(_ *cobra.Command, _ []string) error {
+			return logic.GitCheck(cfg)
+		},
+	}
+
+	cmd.Flags().Bool("all", false, "Check all tracked files instead of the staged ones")
+
+	return cmd
+}
+
+// NewGitMergeCommand creates a new cobra command merging line-mode files for git.
+func NewGitMergeCommand(cfg *config.Config) *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "merge base ours theirs [path]",
+		Short: "Merge line-mode files as a git merge driver",
+		Long: "Decrypt the common ancestor, our version and their version of a line-mode file, merge them line by line\n" +
+			"and encrypt the result into our version, set as merge.encrypt:line.driver (%O %A %B %P) by git install.\n" +
+			"Conflicts are marked around the decrypted lines and encrypted with them, and the command fails\n" +
+			"when any are left. The path of the file in the repository authenticates bound values,\n" +
+			"and the result is bound to it when either side holds bound values.",
+		Args: cobra.RangeArgs(3, 4), //nolint:mnd // the versions and the path
+		PreRunE: func(_ *cobra.Command, args []string) error {
+			cfg.Operation = encrypt.Decrypt
+			cfg.Files = args[:3]
+			cfg.File = args[1]
+
+			if len(args) > 3 { //nolint:mnd // the path
+				cfg.File = args[3]
+			}
+
+			if err := cobraext.Validate(cfg, cfg); err != nil {
+				return fmt.Errorf("validating configuration: %w", err)
+			}
+
+			if cfg.Mode != encrypt.Line {
+				return fmt.Errorf("%w: only line mode files can be merged, got mode %q", config.ErrUsage, cfg.Mode)
+			}
+
+			return requireKey(cfg)
+		},
+		RunE: func(_ *cobra.Command, _ []string) error {
+			return logic.GitMerge(cfg)
+		},
+	}
+
+	cmd.Flags().BoolVar(&cfg.Deterministic, "deterministic", true, "Encrypt deterministically, keeping blobs stable")
+
+	return cmd
+}
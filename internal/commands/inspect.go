@@ -0,0 +1,41 @@
+package commands
+
+import (
+	"fmt"
+
+	"github.com/spf13/cobra"
+
+	"github.com/idelchi/gocry/internal/config"
+	"github.com/idelchi/gocry/internal/encrypt"
+	"github.com/idelchi/gocry/internal/logic"
+	"github.com/idelchi/gogen/pkg/cobraext"
+)
+
+// NewInspectCommand creates a new cobra command showing envelope metadata.
+func NewInspectCommand(cfg *config.Config) *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "inspect file",
+		Short: "Show envelope metadata without decrypting",
+		Long: "Describe the gocry envelopes in a file without decrypting them, so that no key is needed.\n" +
+			"A whole-file envelope is shown with its version, mode, key ID, sizes and layout.\n" +
+			"Other files are scanned for the envelopes of line and structured modes, which are listed by line.",
+		Args: cobra.ExactArgs(1),
+		PreRunE: func(_ *cobra.Command, args []string) error {
+			cfg.Operation = encrypt.Decrypt
+			cfg.File = args[0]
+
+			if err := cobraext.Validate(cfg, cfg); err != nil {
+				return fmt.Errorf("validating configuration: %w", err)
+			}
+
+			return nil
+		},
+		RunE: func(_ *cobra.Command, _ []string) error {
+			return logic.Inspect(cfg)
+		},
+	}
+
+	cmd.Flags().Bool("json", false, "Print the result as JSON")
+
+	return cmd
+}
@@ -0,0 +1,53 @@
+package commands
+
+import (
+	"fmt"
+
+	"github.com/spf13/cobra"
+
+	"github.com/idelchi/gocry/internal/config"
+	"github.com/idelchi/gocry/internal/encrypt"
+	"github.com/idelchi/gocry/internal/logic"
+	"github.com/idelchi/gogen/pkg/cobraext"
+)
+
+// NewKeygenCommand creates a new cobra command for generating keys.
+func NewKeygenCommand(cfg *config.Config) *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "keygen file",
+		Short: "Generate a key file",
+		Long: "Generate a hex-encoded key of the right length for the chosen mode and write it to file (0600).\n" +
+			"The key fingerprint is printed to stdout. With --x25519, an identity file is written\n" +
+			"and its public key (to pass as --recipient) is printed as well.",
+		Args: cobra.ExactArgs(1),
+		PreRunE: func(cmd *cobra.Command, args []string) error {
+			cfg.Operation = encrypt.Encrypt
+			cfg.File = args[0]
+
+			if err := cobraext.Validate(cfg, cfg); err != nil {
+				return fmt.Errorf("validating configuration: %w", err)
+			}
+
+			if cfg.Keygen.Randomized && cmd.Flags().Changed("deterministic") && cfg.Deterministic {
+				return fmt.Errorf("%w: --deterministic and --randomized are mutually exclusive", config.ErrUsage)
+			}
+
+			if cfg.Keygen.Randomized {
+				cfg.Deterministic = false
+			}
+
+			return nil
+		},
+		RunE: func(_ *cobra.Command, _ []string) error {
+			return logic.Keygen(cfg)
+		},
+	}
+
+	cmd.Flags().BoolVar(&cfg.Deterministic, "deterministic", true, "Generate a 64-byte key for deterministic mode")
+	cmd.Flags().Bool("randomized", false, "Generate a 32-byte key for randomized mode")
+	cmd.Flags().Bool("x25519", false, "Generate an X25519 identity for recipient encryption")
+	cmd.Flags().Bool("force", false, "Overwrite an existing key file")
+	cmd.Flags().String("entry", "", "Also print a keyring entry with the given name")
+
+	return cmd
+}
@@ -0,0 +1,51 @@
+package commands
+
+import (
+	"fmt"
+
+	"github.com/spf13/cobra"
+
+	"github.com/idelchi/gocry/internal/config"
+	"github.com/idelchi/gocry/internal/encrypt"
+	"github.com/idelchi/gocry/internal/logic"
+	"github.com/idelchi/gogen/pkg/cobraext"
+)
+
+// NewRekeyCommand creates a new cobra command for the rekey operation.
+func NewRekeyCommand(cfg *config.Config) *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "rekey file...",
+		Short: "Re-encrypt files under a new key",
+		Long: "Re-encrypt files in place under a new key, without writing plaintext to disk.\n" +
+			"The global key options select the current key (or keyring), --new-key or --new-key-file the new one.\n" +
+			"Each envelope keeps its deterministic or randomized mode.",
+		Args: cobra.MinimumNArgs(1),
+		PreRunE: func(_ *cobra.Command, args []string) error {
+			cfg.Operation = encrypt.Decrypt
+			cfg.Files = args
+
+			if err := cobraext.Validate(cfg, cfg); err != nil {
+				return fmt.Errorf("validating configuration: %w", err)
+			}
+
+			if err := requireKey(cfg); err != nil {
+				return err
+			}
+
+			if cfg.Rekey.Key == "" && cfg.Rekey.File == "" {
+				return fmt.Errorf("%w: missing new key: specify either --new-key or --new-key-file", config.ErrUsage)
+			}
+
+			return nil
+		},
+		RunE: func(_ *cobra.Command, _ []string) error {
+			return logic.Rekey(cfg)
+		},
+	}
+
+	cmd.Flags().String("new-key", "", "New encryption key")
+	cmd.Flags().String("new-key-file", "", "Path to the key file with the new encryption key")
+	cmd.Flags().Bool("dry-run", false, "List the files that would change without writing them")
+
+	return cmd
+}
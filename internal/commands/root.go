@@ -6,6 +6,7 @@ import (
 	"github.com/spf13/cobra"
 
 	"github.com/idelchi/gocry/internal/config"
+	"github.com/idelchi/gocry/internal/encrypt"
 	"github.com/idelchi/gogen/pkg/cobraext"
 )
 
@@ -22,13 +23,31 @@ func NewRootCommand(cfg *config.Config, version string) *cobra.Command {
 	root.Flags().IntP("parallel", "j", runtime.NumCPU(), "Number of parallel workers")
 	root.Flags().StringP("key", "k", "", "Encryption key")
 	root.Flags().StringP("key-file", "f", "", "Path to the key file with the encryption key")
-	root.Flags().StringP("mode", "m", "file", "Mode of operation: file or line")
+	root.Flags().String("keyring", "", "Path to a keyring file with several named keys")
+	root.Flags().String("passphrase", "", "Passphrase to derive the encryption key from")
+	root.Flags().Bool("passphrase-prompt", false, "Prompt for the passphrase on the terminal")
+	root.Flags().String("identity", "", "Path to a file with X25519 private keys for decryption")
+	root.Flags().String("kdf", string(encrypt.Argon2id), "Key derivation function for passphrases: argon2id or scrypt")
+	root.Flags().StringP("mode", "m", "file", "Mode of operation: file, line, yaml, json, dotenv, toml or ini")
 	root.Flags().StringP("encrypt", "e", "### DIRECTIVE: ENCRYPT", "Directives for encryption")
 	root.Flags().StringP("decrypt", "d", "### DIRECTIVE: DECRYPT", "Directives for decryption")
+	root.Flags().String("begin", "### DIRECTIVE: BEGIN ENCRYPT", "Directive opening a block of lines to encrypt")
+	root.Flags().String("end", "### DIRECTIVE: END ENCRYPT", "Directive closing a block of lines to encrypt")
+	root.Flags().String("separators", "=:", "Characters separating keys from values in line mode")
 	root.Flags().BoolP("experiments", "x", false, "Enable experimental features")
 	root.Flags().BoolP("quiet", "q", false, "Suppress non-error messages")
 
-	root.AddCommand(NewEncryptCommand(cfg), NewDecryptCommand(cfg))
+	root.AddCommand(
+		NewEncryptCommand(cfg),
+		NewDecryptCommand(cfg),
+		NewRekeyCommand(cfg),
+		NewKeygenCommand(cfg),
+		NewExecCommand(cfg),
+		NewEditCommand(cfg),
+		NewInspectCommand(cfg),
+		NewTextconvCommand(cfg),
+		NewGitCommand(cfg),
+	)
 
 	return root
 }
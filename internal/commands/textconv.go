@@ -0,0 +1,39 @@
+package commands
+
+import (
+	"fmt"
+
+	"github.com/spf13/cobra"
+
+	"github.com/idelchi/gocry/internal/config"
+	"github.com/idelchi/gocry/internal/encrypt"
+	"github.com/idelchi/gocry/internal/logic"
+	"github.com/idelchi/gogen/pkg/cobraext"
+)
+
+// NewTextconvCommand creates a new cobra command converting files for git diffs.
+func NewTextconvCommand(cfg *config.Config) *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "textconv file",
+		Short: "Print a decrypted file for git diffs",
+		Long: "Decrypt a whole-file envelope or a line-mode file to stdout, for use as diff.<driver>.textconv.\n" +
+			"The file is always read from its path. When it cannot be decrypted, for instance without a key,\n" +
+			"a whole-file envelope is replaced by a placeholder describing it instead of failing the diff.",
+		Args: cobra.ExactArgs(1),
+		PreRunE: func(_ *cobra.Command, args []string) error {
+			cfg.Operation = encrypt.Decrypt
+			cfg.File = args[0]
+
+			if err := cobraext.Validate(cfg, cfg); err != nil {
+				return fmt.Errorf("validating configuration: %w", err)
+			}
+
+			return nil
+		},
+		RunE: func(_ *cobra.Command, _ []string) error {
+			return logic.Textconv(cfg)
+		},
+	}
+
+	return cmd
+}
@@ -14,10 +14,96 @@ var ErrUsage = errors.New("usage error")
 // Key represents an encryption key configuration.
 type Key struct {
 	// String is a hexadecimal key string
-	String string `label:"--key" mapstructure:"key" mask:"fixed" validate:"omitempty,exclusive=File,hexadecimal"`
+	String string `label:"--key" mapstructure:"key" mask:"fixed" validate:"omitempty,exclusive=File Keyring Passphrase,hexadecimal"` //nolint:lll
 
 	// File is a path to a file containing a hexadecimal key string
-	File string `label:"--key-file" mapstructure:"key-file" validate:"exclusive=String"`
+	File string `label:"--key-file" mapstructure:"key-file" validate:"exclusive=String Keyring Passphrase"`
+
+	// Keyring is a path to a keyring file with several named keys
+	Keyring string `label:"--keyring" mapstructure:"keyring" validate:"exclusive=String File Passphrase"`
+
+	// Passphrase is a passphrase to derive the key from
+	Passphrase string `label:"--passphrase" mapstructure:"passphrase" mask:"fixed" validate:"exclusive=String File Keyring"`
+
+	// Prompt asks for the passphrase interactively
+	Prompt bool `label:"--passphrase-prompt" mapstructure:"passphrase-prompt"`
+
+	// Recipients are hex-encoded X25519 public keys to encrypt for
+	Recipients []string `label:"--recipient" mapstructure:"recipient"`
+
+	// Identity is a path to a file with hex-encoded X25519 private keys to decrypt with
+	Identity string `label:"--identity" mapstructure:"identity"`
+
+	// KDF is the key derivation function used for passphrases
+	KDF encrypt.KDF `label:"--kdf" mapstructure:"kdf" validate:"oneof=argon2id scrypt"`
+}
+
+// Rekey holds the configuration for re-encrypting content under a new key.
+type Rekey struct {
+	// Key is the new hexadecimal key string
+	Key string `label:"--new-key" mapstructure:"new-key" mask:"fixed" validate:"omitempty,exclusive=File,hexadecimal"`
+
+	// File is a path to a file containing the new hexadecimal key string
+	File string `label:"--new-key-file" mapstructure:"new-key-file" validate:"exclusive=Key"`
+
+	// DryRun lists the files that would change without writing them
+	DryRun bool `mapstructure:"dry-run"`
+}
+
+// Keygen holds the configuration for generating keys.
+type Keygen struct {
+	// Randomized generates a key for randomized mode instead of deterministic mode
+	Randomized bool `mapstructure:"randomized"`
+
+	// X25519 generates an X25519 identity for recipient encryption
+	X25519 bool `mapstructure:"x25519"`
+
+	// Force overwrites an existing key file
+	Force bool `mapstructure:"force"`
+
+	// Entry is the name under which to print a keyring entry for the generated key
+	Entry string `mapstructure:"entry"`
+}
+
+// Output holds the configuration for writing the results of encrypt and decrypt to files.
+type Output struct {
+	// InPlace replaces each file with its result
+	InPlace bool `mapstructure:"in-place"`
+
+	// Dir is a directory to write the results to, keeping the relative paths of the files
+	Dir string `label:"--output-dir" mapstructure:"output-dir"`
+
+	// Suffix is appended to the names of encrypted files, and removed from those of decrypted files
+	Suffix string `label:"--suffix" mapstructure:"suffix"`
+
+	// Recursive processes the files within directories
+	Recursive bool `mapstructure:"recursive"`
+}
+
+// ToFiles reports whether results are written to files instead of stdout.
+func (o Output) ToFiles() bool {
+	return o.InPlace || o.Dir != "" || o.Suffix != ""
+}
+
+// Git holds the configuration for the git commands.
+type Git struct {
+	// Program is the gocry executable the filters and drivers run
+	Program string `mapstructure:"program"`
+
+	// LinePatterns are the `.gitattributes` patterns to encrypt in line mode
+	LinePatterns []string `mapstructure:"line-pattern"`
+
+	// FilePatterns are the `.gitattributes` patterns to encrypt as whole files
+	FilePatterns []string `mapstructure:"file-pattern"`
+
+	// All checks all tracked files instead of the staged ones
+	All bool `mapstructure:"all"`
+}
+
+// Inspect holds the configuration for the inspect command.
+type Inspect struct {
+	// JSON prints the result as JSON
+	JSON bool `mapstructure:"json"`
 }
 
 // Config holds the application's configuration parameters.
@@ -29,7 +115,7 @@ type Config struct {
 	Parallel int `mapstructure:"parallel" validate:"min=1"`
 
 	// Mode is the encryption mode
-	Mode encrypt.Mode `validate:"oneof=file line"`
+	Mode encrypt.Mode `validate:"oneof=file line yaml json dotenv toml ini"`
 
 	// Operation is the encryption operation
 	Operation encrypt.Operation `mapstructure:"-" validate:"oneof=encrypt decrypt"`
@@ -38,7 +124,13 @@ type Config struct {
 	Key Key `mapstructure:",squash"`
 
 	// File is the path to the input file
-	File string `mapstructure:"-" validate:"required"`
+	File string `mapstructure:"-" validate:"required_without=Files"`
+
+	// Files are the paths to the input files for commands operating on several files
+	Files []string `mapstructure:"-" validate:"required_without=File"`
+
+	// Command is the command, with its arguments, run by the exec command
+	Command []string `mapstructure:"-"`
 
 	// Experiments enables experimental features
 	Experiments bool `mapstructure:"experiments"`
@@ -49,8 +141,41 @@ type Config struct {
 	// Deterministic enables deterministic encryption (AES-SIV)
 	Deterministic bool `mapstructure:"deterministic"`
 
+	// Chunked seals deterministic whole files in segments instead of holding them in memory
+	Chunked bool `mapstructure:"chunked"`
+
+	// BindPath authenticates the file path with the ciphertext, so that it only decrypts as that file
+	BindPath bool `mapstructure:"bind-path"`
+
+	// Values encrypts only the value of key-value lines, keeping the key readable
+	Values bool `mapstructure:"values"`
+
+	// Select lists the paths of the values to encrypt in structured modes
+	Select []string `mapstructure:"select"`
+
+	// KeyRegex selects the values to encrypt in structured modes by the keys they are stored under
+	KeyRegex string `mapstructure:"key-regex"`
+
+	// Plaintext lists the keys whose values are left unencrypted in dotenv mode
+	Plaintext []string `mapstructure:"plaintext"`
+
 	// Quiet suppresses non-error messages
 	Quiet bool `mapstructure:"quiet"`
+
+	// Output holds the configuration for writing results to files
+	Output Output `mapstructure:",squash"`
+
+	// Rekey holds the configuration for the rekey command
+	Rekey Rekey `mapstructure:",squash"`
+
+	// Keygen holds the configuration for the keygen command
+	Keygen Keygen `mapstructure:",squash"`
+
+	// Git holds the configuration for the git commands
+	Git Git `mapstructure:",squash"`
+
+	// Inspect holds the configuration for the inspect command
+	Inspect Inspect `mapstructure:",squash"`
 }
 
 // Display returns the value of the Show field.
@@ -38,22 +38,23 @@ func registerExclusive(validator *validator.Validator) error {
 	return nil
 }
 
-// validateExclusive checks if two fields are mutually exclusive.
-// Returns false if both fields have non-empty values.
+// validateExclusive checks if a field is mutually exclusive with the space-separated fields in its parameter.
+// Returns false if the field and any of the other fields have non-empty values.
 func validateExclusive(fl validator.FieldLevel) bool {
-	otherFieldName := fl.Param()
 	field := fl.Field()
-	otherField := fl.Parent().FieldByName(otherFieldName)
 
-	if !field.IsValid() || !otherField.IsValid() {
-		return true
-	}
+	for _, otherFieldName := range strings.Fields(fl.Param()) {
+		otherField := fl.Parent().FieldByName(otherFieldName)
 
-	if field.Kind() == reflect.String && otherField.Kind() == reflect.String {
-		currentValue := field.String()
-		otherValue := otherField.String()
+		if !field.IsValid() || !otherField.IsValid() {
+			continue
+		}
 
-		return currentValue == "" || otherValue == ""
+		if field.Kind() == reflect.String && otherField.Kind() == reflect.String {
+			if field.String() != "" && otherField.String() != "" {
+				return false
+			}
+		}
 	}
 
 	return true
@@ -0,0 +1,201 @@
+// Package dotenv parses dotenv files while keeping every byte of them, so that a file can be written back unchanged.
+package dotenv
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"io"
+	"regexp"
+	"strings"
+)
+
+// ErrSyntax indicates a line that is neither a comment, blank, nor a `KEY=VALUE` assignment.
+var ErrSyntax = errors.New("dotenv syntax error")
+
+// assignment matches the start of an assignment, up to and including the blanks after the `=`.
+var assignment = regexp.MustCompile(`^([ \t]*(?:export[ \t]+)?)([A-Za-z_][A-Za-z0-9_.-]*)([ \t]*=[ \t]*)`)
+
+// Line is a line of a dotenv file, or several lines for a quoted value spanning them.
+// Concatenating Prefix, Value and Suffix reproduces the original text.
+type Line struct {
+	// Prefix holds everything before the value: indentation, `export`, the key and the `=`.
+	// For comments and blank lines it holds the whole line.
+	Prefix string
+
+	// Key is the name of the variable, empty for comments and blank lines
+	Key string
+
+	// Value is the value as written, including any quotes
+	Value string
+
+	// Suffix holds the blanks and comment after the value, and the line ending
+	Suffix string
+}
+
+// IsAssignment reports whether the line assigns a variable.
+func (l Line) IsAssignment() bool {
+	return l.Key != ""
+}
+
+// String returns the original text of the line.
+func (l Line) String() string {
+	return l.Prefix + l.Value + l.Suffix
+}
+
+// Parse splits a dotenv file into its lines.
+func Parse(reader io.Reader) ([]Line, error) {
+	var lines []Line
+
+	buffered := bufio.NewReader(reader)
+	number := 0
+
+	for {
+		text, err := buffered.ReadString('\n')
+		if err != nil && !errors.Is(err, io.EOF) {
+			return nil, fmt.Errorf("reading input: %w", err)
+		}
+
+		if text == "" {
+			return lines, nil
+		}
+
+		number++
+
+		line, err := parseLine(text, buffered, &number)
+		if err != nil {
+			return nil, err
+		}
+
+		lines = append(lines, line)
+	}
+}
+
+// parseLine parses a single line, reading further lines while a quoted value is still open.
+func parseLine(text string, buffered *bufio.Reader, number *int) (Line, error) {
+	trimmed := strings.TrimLeft(text, " \t")
+	if trimmed == "" || strings.HasPrefix(trimmed, "#") || strings.TrimRight(trimmed, "\r\n") == "" {
+		return Line{Prefix: text}, nil
+	}
+
+	match := assignment.FindStringSubmatch(text)
+	if match == nil {
+		return Line{}, fmt.Errorf("%w: line %d: expected KEY=VALUE", ErrSyntax, *number)
+	}
+
+	line := Line{
+		Prefix: match[0],
+		Key:    match[2],
+	}
+
+	rest := text[len(match[0]):]
+
+	if rest == "" || !strings.ContainsRune(`"'`+"`", rune(rest[0])) {
+		blank := strings.TrimRight(match[3], " \t") != match[3]
+		line.Value, line.Suffix = splitUnquoted(rest, blank)
+
+		return line, nil
+	}
+
+	start := *number
+
+	for {
+		if end := closingQuote(rest); end >= 0 {
+			line.Value, line.Suffix = rest[:end+1], rest[end+1:]
+
+			return line, nil
+		}
+
+		next, err := buffered.ReadString('\n')
+		if err != nil && !errors.Is(err, io.EOF) {
+			return Line{}, fmt.Errorf("reading input: %w", err)
+		}
+
+		if next == "" {
+			return Line{}, fmt.Errorf("%w: line %d: unterminated quoted value", ErrSyntax, start)
+		}
+
+		*number++
+		rest += next
+	}
+}
+
+// splitUnquoted splits an unquoted value from the blanks, comment and line ending following it.
+// A `#` starts a comment only when preceded by a blank, as in most dotenv implementations,
+// and blank tells whether the text before rest ends with one.
+func splitUnquoted(rest string, blank bool) (string, string) {
+	end := len(strings.TrimRight(rest, "\r\n"))
+
+	for idx := range end {
+		if rest[idx] == '#' && ((idx == 0 && blank) || (idx > 0 && (rest[idx-1] == ' ' || rest[idx-1] == '\t'))) {
+			end = idx
+
+			break
+		}
+	}
+
+	value := strings.TrimRight(rest[:end], " \t")
+
+	return value, rest[len(value):]
+}
+
+// closingQuote returns the index of the quote closing the value at the start of rest, or -1 if it is not closed.
+// Backslashes escape characters only within double quotes.
+func closingQuote(rest string) int {
+	quote := rest[0]
+
+	for idx := 1; idx < len(rest); idx++ {
+		switch rest[idx] {
+		case '\\':
+			if quote == '"' {
+				idx++
+			}
+		case quote:
+			return idx
+		}
+	}
+
+	return -1
+}
+
+// Unquote returns the value a raw value stands for.
+// Single quotes and backticks keep their content literally, while double quotes interpret the escapes
+// `\n`, `\r`, `\t`, `\\`, `\"` and `\$`, leaving other backslashes as they are. Unquoted values are returned unchanged.
+func Unquote(value string) string {
+	if len(value) < 2 || value[0] != value[len(value)-1] || !strings.ContainsRune(`"'`+"`", rune(value[0])) {
+		return value
+	}
+
+	inner := value[1 : len(value)-1]
+	if value[0] != '"' {
+		return inner
+	}
+
+	var unquoted strings.Builder
+
+	for idx := 0; idx < len(inner); idx++ {
+		if inner[idx] != '\\' || idx+1 == len(inner) {
+			unquoted.WriteByte(inner[idx])
+
+			continue
+		}
+
+		idx++
+
+		switch inner[idx] {
+		case 'n':
+			unquoted.WriteByte('\n')
+		case 'r':
+			unquoted.WriteByte('\r')
+		case 't':
+			unquoted.WriteByte('\t')
+		case '\\', '"', '$':
+			unquoted.WriteByte(inner[idx])
+		default:
+			unquoted.WriteByte('\\')
+			unquoted.WriteByte(inner[idx])
+		}
+	}
+
+	return unquoted.String()
+}
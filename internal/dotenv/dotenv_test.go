@@ -0,0 +1,148 @@
+package dotenv
+
+import (
+	"errors"
+	"strings"
+	"testing"
+)
+
+func TestParse(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		name  string
+		input string
+		want  []Line
+	}{
+		{
+			name:  "plain",
+			input: "KEY=value\n",
+			want:  []Line{{Prefix: "KEY=", Key: "KEY", Value: "value", Suffix: "\n"}},
+		},
+		{
+			name:  "export and blanks",
+			input: "  export  KEY = value  \n",
+			want:  []Line{{Prefix: "  export  KEY = ", Key: "KEY", Value: "value", Suffix: "  \n"}},
+		},
+		{
+			name:  "inline comment",
+			input: "KEY=value # comment\n",
+			want:  []Line{{Prefix: "KEY=", Key: "KEY", Value: "value", Suffix: " # comment\n"}},
+		},
+		{
+			name:  "hash in value",
+			input: "COLOR=#fff\nURL=http://host/#anchor\n",
+			want: []Line{
+				{Prefix: "COLOR=", Key: "COLOR", Value: "#fff", Suffix: "\n"},
+				{Prefix: "URL=", Key: "URL", Value: "http://host/#anchor", Suffix: "\n"},
+			},
+		},
+		{
+			name:  "comment only",
+			input: "KEY= # comment\n",
+			want:  []Line{{Prefix: "KEY= ", Key: "KEY", Value: "", Suffix: "# comment\n"}},
+		},
+		{
+			name:  "empty value",
+			input: "KEY=\n",
+			want:  []Line{{Prefix: "KEY=", Key: "KEY", Value: "", Suffix: "\n"}},
+		},
+		{
+			name:  "double quotes with escapes",
+			input: `KEY="a \"b\" # c\n" # comment` + "\n",
+			want:  []Line{{Prefix: "KEY=", Key: "KEY", Value: `"a \"b\" # c\n"`, Suffix: " # comment\n"}},
+		},
+		{
+			name:  "single quotes keep backslashes",
+			input: `KEY='a\' # c` + "\n",
+			want:  []Line{{Prefix: "KEY=", Key: "KEY", Value: `'a\'`, Suffix: " # c\n"}},
+		},
+		{
+			name:  "multi-line value",
+			input: "KEY=\"line 1\nline 2\"\nNEXT=x",
+			want: []Line{
+				{Prefix: "KEY=", Key: "KEY", Value: "\"line 1\nline 2\"", Suffix: "\n"},
+				{Prefix: "NEXT=", Key: "NEXT", Value: "x", Suffix: ""},
+			},
+		},
+		{
+			name:  "comments and blank lines",
+			input: "# comment\n\n  \r\n",
+			want:  []Line{{Prefix: "# comment\n"}, {Prefix: "\n"}, {Prefix: "  \r\n"}},
+		},
+		{
+			name:  "crlf",
+			input: "KEY=value\r\n",
+			want:  []Line{{Prefix: "KEY=", Key: "KEY", Value: "value", Suffix: "\r\n"}},
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			t.Parallel()
+
+			lines, err := Parse(strings.NewReader(test.input))
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			if len(lines) != len(test.want) {
+				t.Fatalf("got %d lines, want %d: %+v", len(lines), len(test.want), lines)
+			}
+
+			var text strings.Builder
+
+			for idx, line := range lines {
+				if line != test.want[idx] {
+					t.Fatalf("line %d: got %+v, want %+v", idx, line, test.want[idx])
+				}
+
+				text.WriteString(line.String())
+			}
+
+			if text.String() != test.input {
+				t.Fatalf("lines give %q, want %q", text.String(), test.input)
+			}
+		})
+	}
+}
+
+func TestParseErrors(t *testing.T) {
+	t.Parallel()
+
+	for _, input := range []string{
+		"not an assignment\n",
+		"=value\n",
+		"1KEY=value\n",
+		"KEY value\n",
+		"KEY=\"unterminated\n",
+		"KEY='also\nunterminated\n",
+	} {
+		if _, err := Parse(strings.NewReader(input)); !errors.Is(err, ErrSyntax) {
+			t.Fatalf("parsing %q: got %v, want %v", input, err, ErrSyntax)
+		}
+	}
+}
+
+func TestUnquote(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		value string
+		want  string
+	}{
+		{value: `plain`, want: `plain`},
+		{value: `"a\nb\t\"c\" \\ \$HOME \x"`, want: "a\nb\t\"c\" \\ $HOME \\x"},
+		{value: `'a\nb $HOME'`, want: `a\nb $HOME`},
+		{value: "`a\\nb`", want: `a\nb`},
+		{value: `"mismatched'`, want: `"mismatched'`},
+		{value: `"`, want: `"`},
+		{value: `"trailing\"`, want: `trailing\`},
+	}
+
+	for _, test := range tests {
+		if got := Unquote(test.value); got != test.want {
+			t.Fatalf("unquoting %s: got %q, want %q", test.value, got, test.want)
+		}
+	}
+}
@@ -0,0 +1,50 @@
+package encrypt
+
+import (
+	"crypto/sha256"
+	"fmt"
+	"path/filepath"
+)
+
+// pathBinding returns the associated data binding an envelope to path.
+// The path is cleaned and uses forward slashes, so that bindings match across platforms.
+func pathBinding(path string) []byte {
+	digest := sha256.Sum256([]byte("gocry/path:" + filepath.ToSlash(filepath.Clean(path))))
+
+	return digest[:]
+}
+
+// sealBinding returns the binding to authenticate new envelopes with, or nil if binding is disabled.
+func (e *Encryptor) sealBinding() ([]byte, error) {
+	if !e.BindPath {
+		return nil, nil
+	}
+
+	if e.Path == "" {
+		return nil, fmt.Errorf("%w: binding to the file path requires a path", ErrProcessing)
+	}
+
+	return pathBinding(e.Path), nil
+}
+
+// openBinding returns the binding an envelope with the given header was authenticated with, or nil if it is unbound.
+func (e *Encryptor) openBinding(header envelopeHeader) ([]byte, error) {
+	if !header.bound {
+		return nil, nil
+	}
+
+	if e.Path == "" {
+		return nil, fmt.Errorf("%w: data is bound to its file path, but no path was supplied", ErrKeyMismatch)
+	}
+
+	return pathBinding(e.Path), nil
+}
+
+// explainBinding adds a hint to a decryption error of a bound envelope, since a moved file fails like tampered data.
+func (e *Encryptor) explainBinding(header envelopeHeader, err error) error {
+	if err == nil || !header.bound {
+		return err
+	}
+
+	return fmt.Errorf("%w (data is bound to its file path, was it encrypted as %q?)", err, e.Path)
+}
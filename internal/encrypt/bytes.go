@@ -12,8 +12,9 @@ import (
 
 // encryptBytes encrypts the given byte slice using AES-CTR with an HMAC tag.
 // Output layout: [header | IV | ciphertext | tag].
-func (e *Encryptor) encryptBytes(data []byte) ([]byte, error) {
-	encKey, macKey, err := deriveRandomizedKeys(e.Key)
+// A non-nil binding is authenticated by the tag, but not stored.
+func encryptBytes(key, data, binding []byte) ([]byte, error) {
+	encKey, macKey, err := deriveRandomizedKeys(key)
 	if err != nil {
 		return nil, err
 	}
@@ -23,7 +24,11 @@ func (e *Encryptor) encryptBytes(data []byte) ([]byte, error) {
 		return nil, fmt.Errorf("creating cipher: %w", err)
 	}
 
-	header := newEnvelopeHeader(modeRandomized)
+	header, err := newEnvelopeHeader(modeRandomized.bind(binding), key)
+	if err != nil {
+		return nil, err
+	}
+
 	totalLen := len(header) + aes.BlockSize + len(data) + envelopeTagSize
 	out := make([]byte, totalLen)
 
@@ -48,6 +53,7 @@ func (e *Encryptor) encryptBytes(data []byte) ([]byte, error) {
 
 	mac := hmac.New(sha256.New, macKey)
 	mac.Write(out[:offset])
+	mac.Write(binding)
 
 	tag := mac.Sum(nil)
 	copy(out[offset:], tag)
@@ -55,37 +61,36 @@ func (e *Encryptor) encryptBytes(data []byte) ([]byte, error) {
 	return out, nil
 }
 
-// decryptBytes decrypts data produced by encryptBytes.
-func (e *Encryptor) decryptBytes(ciphertext []byte) ([]byte, error) {
-	if len(ciphertext) < envelopeHeaderSize+aes.BlockSize+envelopeTagSize {
-		return nil, fmt.Errorf("%w: ciphertext too short", ErrProcessing)
-	}
-
-	encKey, macKey, err := deriveRandomizedKeys(e.Key)
+// decryptBytes decrypts data produced by encryptBytes, with the same binding.
+func decryptBytes(key, ciphertext, binding []byte) ([]byte, error) {
+	header, err := parseEnvelopeHeader(ciphertext)
 	if err != nil {
 		return nil, err
 	}
 
-	header := ciphertext[:envelopeHeaderSize]
+	if header.mode != modeRandomized {
+		return nil, fmt.Errorf("%w: unexpected mode for randomized decryption", ErrProcessing)
+	}
 
-	mode, err := parseEnvelopeHeader(header)
-	if err != nil {
-		return nil, err
+	if len(ciphertext) < header.size()+aes.BlockSize+envelopeTagSize {
+		return nil, fmt.Errorf("%w: ciphertext too short", ErrProcessing)
 	}
 
-	if mode != modeRandomized {
-		return nil, fmt.Errorf("%w: unexpected mode for randomized decryption", ErrProcessing)
+	encKey, macKey, err := deriveRandomizedKeys(key)
+	if err != nil {
+		return nil, err
 	}
 
 	mac := hmac.New(sha256.New, macKey)
 	mac.Write(ciphertext[:len(ciphertext)-envelopeTagSize])
+	mac.Write(binding)
 
 	tag := ciphertext[len(ciphertext)-envelopeTagSize:]
 	if !hmac.Equal(mac.Sum(nil), tag) {
 		return nil, fmt.Errorf("%w: authentication failed", ErrProcessing)
 	}
 
-	ivStart := envelopeHeaderSize
+	ivStart := header.size()
 	ivEnd := ivStart + aes.BlockSize
 	initializationVector := ciphertext[ivStart:ivEnd]
 
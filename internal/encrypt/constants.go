@@ -31,4 +31,24 @@ const (
 	// It treats the entire input as one piece of data to be
 	// encrypted/decrypted, suitable for binary files or whole-file encryption.
 	File Mode = "file"
+
+	// YAML mode parses the input as YAML and processes the selected scalar values.
+	// Keys, comments and ordering are kept, so that encrypted documents stay reviewable,
+	// while indentation and blank lines are normalized.
+	YAML Mode = "yaml"
+
+	// JSON mode parses the input as JSON and processes the selected values.
+	// Only the processed values are replaced, so key order and formatting are kept.
+	JSON Mode = "json"
+
+	// Dotenv mode parses the input as a dotenv file and encrypts the value of every assignment.
+	// The values are encrypted as written, so that decryption reproduces the file byte for byte.
+	Dotenv Mode = "dotenv"
+
+	// TOML mode processes the selected values of a TOML document line by line.
+	// Values are encrypted as written into strings, keeping comments and layout.
+	TOML Mode = "toml"
+
+	// INI mode processes the selected values of an INI file line by line, keeping comments and layout.
+	INI Mode = "ini"
 )
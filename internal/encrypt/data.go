@@ -1,31 +1,87 @@
 package encrypt
 
 import (
+	"bytes"
 	"encoding/base64"
 	"fmt"
+	"io"
 )
 
 // encryptData encrypts the given data and encodes it in base64.
 // This is used for line-mode encryption where the output needs to be
 // safely represented as a string in the output file.
 func (e *Encryptor) encryptData(data []byte) ([]byte, error) {
+	key, prefix, err := e.encryptionKey()
+	if err != nil {
+		return nil, err
+	}
+
+	binding, err := e.sealBinding()
+	if err != nil {
+		return nil, err
+	}
+
+	envelope, err := sealEnvelope(e.envelopeMode(), key, data, binding)
+	if err != nil {
+		return nil, err
+	}
+
+	return []byte(base64.StdEncoding.EncodeToString(append(prefix, envelope...))), nil
+}
+
+// envelopeMode returns the envelope mode used for encryption.
+func (e *Encryptor) envelopeMode() envelopeMode {
 	if e.Deterministic {
-		out, err := e.encryptDeterministic(data)
-		if err != nil {
+		return modeDeterministic
+	}
+
+	return modeRandomized
+}
+
+// encryptionKey returns the key used for encryption, together with any prefix
+// that must precede the envelope (the header of a passphrase or recipients envelope).
+func (e *Encryptor) encryptionKey() ([]byte, []byte, error) {
+	if e.Recipients != nil {
+		if e.Deterministic {
+			return nil, nil, fmt.Errorf("%w: encryption for recipients is always randomized", ErrProcessing)
+		}
+
+		return e.Recipients.prefix()
+	}
+
+	if e.Passphrase != nil {
+		return e.Passphrase.prefix(e.envelopeMode())
+	}
+
+	return e.Keyring.Primary().Material, nil, nil
+}
+
+// sealEnvelope encrypts data in memory with the given mode, returning a complete envelope.
+// A non-nil binding is authenticated and recorded as the bound flag.
+func sealEnvelope(mode envelopeMode, key, data, binding []byte) ([]byte, error) {
+	switch mode {
+	case modeRandomized:
+		return encryptBytes(key, data, binding)
+	case modeSegmented, modeSegmentedDeterministic:
+		var out bytes.Buffer
+		if err := encryptSegmented(mode, key, binding, bytes.NewReader(data), &out, 1); err != nil {
 			return nil, err
 		}
 
-		envelope := append(newEnvelopeHeader(modeDeterministic), out...)
+		return out.Bytes(), nil
+	}
 
-		return []byte(base64.StdEncoding.EncodeToString(envelope)), nil
+	out, err := encryptDeterministic(key, data, binding)
+	if err != nil {
+		return nil, err
 	}
 
-	ciphertext, err := e.encryptBytes(data)
+	header, err := newEnvelopeHeader(modeDeterministic.bind(binding), key)
 	if err != nil {
 		return nil, err
 	}
 
-	return []byte(base64.StdEncoding.EncodeToString(ciphertext)), nil
+	return append(header, out...), nil
 }
 
 // decryptData decodes the base64 data and decrypts it.
@@ -37,29 +93,171 @@ func (e *Encryptor) decryptData(data []byte) ([]byte, error) {
 		return nil, fmt.Errorf("decoding base64: %w", err)
 	}
 
-	if len(ciphertext) < envelopeHeaderSize {
-		return nil, fmt.Errorf("%w: ciphertext too short", ErrProcessing)
+	return e.decryptEnvelope(ciphertext)
+}
+
+// decryptEnvelope decrypts a complete envelope held in memory,
+// unwrapping a passphrase or recipients envelope if needed.
+func (e *Encryptor) decryptEnvelope(ciphertext []byte) ([]byte, error) {
+	header, err := parseEnvelopeHeader(ciphertext)
+	if err != nil {
+		return nil, err
 	}
 
-	mode, err := parseEnvelopeHeader(ciphertext[:envelopeHeaderSize])
+	keyring := e.Keyring
+
+	if header.mode.wraps() {
+		reader := bytes.NewReader(ciphertext[header.size():])
+
+		keyring, err = e.unwrapKeyring(header, reader)
+		if err != nil {
+			return nil, err
+		}
+
+		ciphertext = ciphertext[len(ciphertext)-reader.Len():]
+
+		header, err = parseInnerEnvelopeHeader(ciphertext)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	binding, err := e.openBinding(header)
 	if err != nil {
 		return nil, err
 	}
 
-	switch mode {
-	case modeDeterministic:
-		if len(e.Key) != deterministicKeyLen {
-			return nil, fmt.Errorf("%w: deterministic data requires 64-byte key (128 hex chars)", ErrProcessing)
+	plaintext, err := openEnvelope(keyring, header, ciphertext, binding)
+
+	return plaintext, e.explainBinding(header, err)
+}
+
+// unwrapKeyring reads the key material following the header of a passphrase or recipients envelope
+// from reader, and returns a keyring holding the key of the inner envelope.
+func (e *Encryptor) unwrapKeyring(header envelopeHeader, reader io.Reader) (*Keyring, error) {
+	switch header.mode {
+	case modePassphrase:
+		if e.Passphrase == nil {
+			return nil, fmt.Errorf("%w: data is encrypted with a passphrase, but none was supplied", ErrKeyMismatch)
 		}
 
-		return e.decryptDeterministic(ciphertext[envelopeHeaderSize:])
-	case modeRandomized:
-		if len(e.Key) != randomizedKeyLen {
-			return nil, fmt.Errorf("%w: randomized data requires 32-byte key (64 hex chars)", ErrProcessing)
+		return e.Passphrase.keyring(header, reader)
+	case modeRecipients:
+		dataKey, err := unwrapDataKey(e.Identities, reader)
+		if err != nil {
+			return nil, err
 		}
 
-		return e.decryptBytes(ciphertext)
+		return NewKeyring([]Key{{Name: "data key", Material: dataKey}}, "")
 	default:
-		return nil, fmt.Errorf("%w: unsupported mode", ErrProcessing)
+		return nil, fmt.Errorf("%w: mode %d does not wrap an envelope", ErrProcessing, header.mode)
+	}
+}
+
+// innerEnvelope returns the envelope wrapped by a passphrase or recipients envelope.
+func innerEnvelope(header envelopeHeader, ciphertext []byte) ([]byte, error) {
+	offset := header.size()
+
+	switch header.mode {
+	case modePassphrase:
+		offset += kdfParamsSize
+	case modeRecipients:
+		if len(ciphertext) <= offset {
+			return nil, fmt.Errorf("%w: ciphertext too short", ErrProcessing)
+		}
+
+		offset += 1 + int(ciphertext[offset])*stanzaSize
+	}
+
+	if len(ciphertext) < offset {
+		return nil, fmt.Errorf("%w: ciphertext too short", ErrProcessing)
+	}
+
+	return ciphertext[offset:], nil
+}
+
+// parseInnerEnvelopeHeader parses the header of an envelope nested inside a passphrase or recipients envelope.
+func parseInnerEnvelopeHeader(data []byte) (envelopeHeader, error) {
+	header, err := parseEnvelopeHeader(data)
+	if err != nil {
+		return envelopeHeader{}, err
+	}
+
+	if header.mode.wraps() {
+		return envelopeHeader{}, fmt.Errorf("%w: nested wrapping envelope", ErrProcessing)
+	}
+
+	return header, nil
+}
+
+// openEnvelope decrypts a complete envelope with the given header and binding,
+// trying every keyring key that may have produced it.
+func openEnvelope(keyring *Keyring, header envelopeHeader, ciphertext, binding []byte) ([]byte, error) {
+	keys, err := keyring.candidates(header)
+	if err != nil {
+		return nil, err
 	}
+
+	for _, key := range keys {
+		var plaintext []byte
+
+		switch header.mode {
+		case modeDeterministic:
+			plaintext, err = decryptDeterministic(key.Material, ciphertext[header.size():], binding)
+		case modeRandomized:
+			plaintext, err = decryptBytes(key.Material, ciphertext, binding)
+		case modeSegmented, modeSegmentedDeterministic:
+			var out bytes.Buffer
+
+			raw, body := ciphertext[:header.size()], ciphertext[header.size():]
+			err = decryptSegmented(key.Material, binding, bytes.NewReader(body), &out, raw, 1)
+			plaintext = out.Bytes()
+		default:
+			return nil, fmt.Errorf("%w: unsupported mode", ErrProcessing)
+		}
+
+		if err == nil {
+			return plaintext, nil
+		}
+	}
+
+	return nil, err
+}
+
+// IsEncrypted reports whether data starts with an envelope header, as whole files encrypted by gocry do.
+func IsEncrypted(data []byte) bool {
+	_, err := parseEnvelopeHeader(data)
+
+	return err == nil
+}
+
+// MatchEnvelope configures the encryptor to encrypt the way the whole-file envelope at the start of data was:
+// deterministic or randomized, chunked or not, and bound to its path or not.
+// Passphrase envelopes are matched by the envelope they wrap.
+// Recipients envelopes cannot be matched, since their public keys are not known.
+func (e *Encryptor) MatchEnvelope(data []byte) error {
+	header, err := parseEnvelopeHeader(data)
+	if err != nil {
+		return err
+	}
+
+	switch header.mode {
+	case modeRecipients:
+		return fmt.Errorf("%w: data encrypted for recipients cannot be re-encrypted for them", ErrProcessing)
+	case modePassphrase:
+		inner, err := innerEnvelope(header, data)
+		if err != nil {
+			return err
+		}
+
+		if header, err = parseInnerEnvelopeHeader(inner); err != nil {
+			return err
+		}
+	}
+
+	e.Deterministic = header.mode.deterministic()
+	e.Chunked = header.mode == modeSegmentedDeterministic
+	e.BindPath = header.bound
+
+	return nil
 }
@@ -2,7 +2,9 @@ package encrypt
 
 import (
 	"bytes"
+	"crypto/sha256"
 	"fmt"
+	"sync"
 
 	"github.com/tink-crypto/tink-go/v2/daead"
 	"github.com/tink-crypto/tink-go/v2/insecurecleartextkeyset"
@@ -14,10 +16,51 @@ import (
 	"google.golang.org/protobuf/proto"
 )
 
-// newDAEAD creates a deterministic AEAD primitive from a 64-byte key.
+// daeadCacheSize bounds the number of cached primitives, as passphrase-derived keys may vary per envelope.
+const daeadCacheSize = 16
+
+// daeadCache holds the primitives created by newDAEAD, indexed by a hash of their key.
+// Creating a primitive is comparatively expensive, while a long-running filter process
+// encrypts and decrypts many files with the same key. Tink primitives are safe for concurrent use.
+//
+//nolint:gochecknoglobals // process-wide cache
+var daeadCache = struct {
+	sync.Mutex
+
+	primitives map[[sha256.Size]byte]tink.DeterministicAEAD
+}{primitives: make(map[[sha256.Size]byte]tink.DeterministicAEAD)}
+
+// newDAEAD returns a deterministic AEAD primitive for a 64-byte key, reusing a cached one where possible.
 //
 //nolint:ireturn 		// method must return an interface
 func newDAEAD(key []byte) (tink.DeterministicAEAD, error) {
+	id := sha256.Sum256(key)
+
+	daeadCache.Lock()
+	defer daeadCache.Unlock()
+
+	if primitive, ok := daeadCache.primitives[id]; ok {
+		return primitive, nil
+	}
+
+	primitive, err := createDAEAD(key)
+	if err != nil {
+		return nil, err
+	}
+
+	if len(daeadCache.primitives) >= daeadCacheSize {
+		clear(daeadCache.primitives)
+	}
+
+	daeadCache.primitives[id] = primitive
+
+	return primitive, nil
+}
+
+// createDAEAD creates a deterministic AEAD primitive from a 64-byte key.
+//
+//nolint:ireturn 		// method must return an interface
+func createDAEAD(key []byte) (tink.DeterministicAEAD, error) {
 	aesSivKey := &aes_sivpb.AesSivKey{Version: 0, KeyValue: key}
 
 	serializedKey, err := proto.Marshal(aesSivKey)
@@ -1,5 +1,6 @@
-// Package encrypt provides a secure, flexible encryption system for handling both file and line-based encryption
-// operations. Randomized mode uses AES-CTR protected with an HMAC-SHA256 tag derived via HKDF, while deterministic
-// mode relies on AES-SIV. It supports parallel processing for line-mode operations and maintains compatibility
-// with text-based workflows through automatic base64 encoding.
+// Package encrypt provides a secure, flexible encryption system for handling file, line-based and structured
+// encryption operations. Randomized mode seals whole files in AES-GCM segments and lines with AES-CTR protected
+// by an HMAC-SHA256 tag derived via HKDF, while deterministic mode relies on AES-SIV. It supports parallel
+// processing and maintains compatibility with text-based workflows through automatic base64 encoding.
+// Structured modes (YAML, JSON, dotenv, TOML and INI) encrypt only the selected values of a document.
 package encrypt
@@ -0,0 +1,64 @@
+package encrypt
+
+import (
+	"fmt"
+	"io"
+	"slices"
+
+	"github.com/idelchi/gocry/internal/dotenv"
+)
+
+// processDotenv encrypts or decrypts the values of a dotenv file.
+// Every assignment is encrypted unless its key is kept in plaintext or not selected,
+// and the value is encrypted as written, quotes included, so that decryption reproduces the file byte for byte.
+// Returns true if any value was encrypted or decrypted.
+func (e *Encryptor) processDotenv(reader io.Reader, writer io.Writer) (bool, error) {
+	lines, err := dotenv.Parse(reader)
+	if err != nil {
+		return false, fmt.Errorf("%w: parsing dotenv: %w", ErrProcessing, err)
+	}
+
+	var anyProcessed bool
+
+	for idx, line := range lines {
+		if !line.IsAssignment() || line.Value == "" {
+			continue
+		}
+
+		switch e.Operation {
+		case Encrypt:
+			if isEncryptedValue(line.Value) || slices.Contains(e.Plaintext, line.Key) ||
+				!e.Selector.Matches([]string{line.Key}) {
+				continue
+			}
+
+			value, err := e.encryptValue(line.Value, "")
+			if err != nil {
+				return false, fmt.Errorf("encrypting %q: %w", line.Key, err)
+			}
+
+			lines[idx].Value = value
+		case Decrypt:
+			if !isEncryptedValue(line.Value) {
+				continue
+			}
+
+			value, _, err := e.decryptValue(line.Value)
+			if err != nil {
+				return false, fmt.Errorf("decrypting %q: %w", line.Key, err)
+			}
+
+			lines[idx].Value = value
+		}
+
+		anyProcessed = true
+	}
+
+	for _, line := range lines {
+		if _, err := io.WriteString(writer, line.String()); err != nil {
+			return false, fmt.Errorf("%w: writing error: %w", ErrProcessing, err)
+		}
+	}
+
+	return anyProcessed, nil
+}
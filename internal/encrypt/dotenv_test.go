@@ -0,0 +1,47 @@
+package encrypt
+
+import (
+	"bytes"
+	"errors"
+	"strings"
+	"testing"
+)
+
+func TestDotenvPlaintext(t *testing.T) {
+	t.Parallel()
+
+	input := []byte("APP_ENV=production\nPASSWORD=hunter2\n")
+
+	encryptor := Encryptor{
+		Keyring:   testKeyring(t, randomizedKeyLen),
+		Mode:      Dotenv,
+		Plaintext: []string{"APP_ENV"},
+		Parallel:  1,
+	}
+
+	encrypted := roundTrip(t, encryptor, input, input)
+
+	if !bytes.HasPrefix(encrypted, []byte("APP_ENV=production\nPASSWORD=")) || bytes.Contains(encrypted, []byte("hunter2")) {
+		t.Fatalf("unexpected selection: %s", encrypted)
+	}
+}
+
+func TestDotenvRejects(t *testing.T) {
+	t.Parallel()
+
+	encryptor := Encryptor{Keyring: testKeyring(t, randomizedKeyLen), Mode: Dotenv, Parallel: 1}
+
+	encrypted, err := process(t, encryptor, Encrypt, []byte("PASSWORD=hunter2\n"))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	value := strings.TrimSuffix(strings.TrimPrefix(string(encrypted), "PASSWORD="), "\n")
+
+	// A value cannot be retyped without the key
+	input := "PASSWORD=" + value + ":number\n"
+
+	if _, err := process(t, encryptor, Decrypt, []byte(input)); !errors.Is(err, ErrProcessing) {
+		t.Fatalf("got %v, want %v", err, ErrProcessing)
+	}
+}
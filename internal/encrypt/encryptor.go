@@ -1,6 +1,7 @@
 package encrypt
 
 import (
+	"crypto/ecdh"
 	"fmt"
 	"io"
 )
@@ -13,12 +14,31 @@ type Directives struct {
 
 	// Decrypt specifies the prefix that marks encrypted content
 	Decrypt string `mapstructure:"decrypt"`
+
+	// Begin specifies the line that opens a block of lines to encrypt as one
+	Begin string `mapstructure:"begin"`
+
+	// End specifies the line that closes a block opened by Begin
+	End string `mapstructure:"end"`
+
+	// Separators lists the characters separating a key from its value, for value-only encryption
+	Separators string `mapstructure:"separators"`
 }
 
 // Encryptor handles encryption and decryption operations.
 type Encryptor struct {
-	// Key is the encryption key used for AES cipher operations
-	Key []byte
+	// Keyring holds the keys used for AES cipher operations.
+	// Its primary key encrypts, all of its keys are tried for decryption.
+	Keyring *Keyring
+
+	// Passphrase, when set, derives the keys instead of the keyring
+	Passphrase *Passphrase
+
+	// Recipients, when set, encrypts for X25519 public keys instead of with the keyring
+	Recipients *Recipients
+
+	// Identities are the X25519 private keys used to decrypt data encrypted for recipients
+	Identities []*ecdh.PrivateKey
 
 	// Operation specifies whether to encrypt or decrypt
 	Operation Operation
@@ -34,6 +54,24 @@ type Encryptor struct {
 
 	// Deterministic toggles deterministic encryption (AES-SIV)
 	Deterministic bool
+
+	// Chunked seals deterministic whole files in AES-SIV segments, keeping memory use bounded
+	Chunked bool
+
+	// Path is the path of the file being processed, authenticated by envelopes bound to it
+	Path string
+
+	// BindPath binds new envelopes to Path, so that they only decrypt as that file
+	BindPath bool
+
+	// Values encrypts only the value of key-value lines in line mode, keeping the key readable
+	Values bool
+
+	// Selector chooses the values to encrypt in structured modes, nil selects all of them
+	Selector *Selector
+
+	// Plaintext lists the keys whose values are left unencrypted in dotenv mode
+	Plaintext []string
 }
 
 // Process handles encryption and decryption based on the provided configuration.
@@ -41,6 +79,10 @@ type Encryptor struct {
 // The processing mode (Line or File) determines how the input is handled:
 //   - Line mode processes the input line-by-line, maintaining order
 //   - File mode treats the entire input as a single block of data
+//   - YAML mode processes the selected values of a YAML document
+//   - JSON mode processes the selected values of a JSON document
+//   - Dotenv mode processes the values of a dotenv file
+//   - TOML and INI modes process the selected values of TOML and INI files
 func (e *Encryptor) Process(reader io.Reader, writer io.Writer) (bool, error) {
 	switch e.Mode {
 	case Line:
@@ -51,6 +93,16 @@ func (e *Encryptor) Process(reader io.Reader, writer io.Writer) (bool, error) {
 		return e.processLinesExperiments(reader, writer)
 	case File:
 		return e.processWholeFile(reader, writer)
+	case YAML:
+		return e.processYAML(reader, writer)
+	case JSON:
+		return e.processJSON(reader, writer)
+	case Dotenv:
+		return e.processDotenv(reader, writer)
+	case TOML:
+		return e.processTOML(reader, writer)
+	case INI:
+		return e.processINI(reader, writer)
 	default:
 		return false, fmt.Errorf("invalid mode: %s", e.Mode) //nolint: err113	// generic error
 	}
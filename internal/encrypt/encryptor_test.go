@@ -0,0 +1,77 @@
+package encrypt
+
+import (
+	"bytes"
+	"crypto/rand"
+	"testing"
+)
+
+// testDirectives are the default directives of the command line.
+//
+//nolint:gochecknoglobals // these globals are acceptable
+var testDirectives = Directives{
+	Encrypt:    "### DIRECTIVE: ENCRYPT",
+	Decrypt:    "### DIRECTIVE: DECRYPT",
+	Begin:      "### DIRECTIVE: BEGIN ENCRYPT",
+	End:        "### DIRECTIVE: END ENCRYPT",
+	Separators: "=:",
+}
+
+// testKey returns a random key of the given length.
+func testKey(t *testing.T, keyLen int) []byte {
+	t.Helper()
+
+	key := make([]byte, keyLen)
+	if _, err := rand.Read(key); err != nil {
+		t.Fatal(err)
+	}
+
+	return key
+}
+
+// testKeyring returns a keyring holding a random key of the given length.
+func testKeyring(t *testing.T, keyLen int) *Keyring {
+	t.Helper()
+
+	keyring, err := NewKeyring([]Key{{Name: "test", Material: testKey(t, keyLen)}}, "")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return keyring
+}
+
+// process runs encryptor over input with the given operation.
+func process(t *testing.T, encryptor Encryptor, operation Operation, input []byte) ([]byte, error) {
+	t.Helper()
+
+	encryptor.Operation = operation
+
+	var out bytes.Buffer
+
+	_, err := encryptor.Process(bytes.NewReader(input), &out)
+
+	return out.Bytes(), err
+}
+
+// roundTrip encrypts input and decrypts it again, failing the test unless that gives back want.
+// It returns the ciphertext.
+func roundTrip(t *testing.T, encryptor Encryptor, input, want []byte) []byte {
+	t.Helper()
+
+	encrypted, err := process(t, encryptor, Encrypt, input)
+	if err != nil {
+		t.Fatalf("encrypting: %v", err)
+	}
+
+	decrypted, err := process(t, encryptor, Decrypt, encrypted)
+	if err != nil {
+		t.Fatalf("decrypting: %v", err)
+	}
+
+	if !bytes.Equal(decrypted, want) {
+		t.Fatalf("round trip gave %q, want %q", decrypted, want)
+	}
+
+	return encrypted
+}
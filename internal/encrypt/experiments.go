@@ -9,7 +9,7 @@ import (
 
 // jscpd:ignore-start
 
-// processLines processes each line of the input data sequentially.
+// processLinesExperiments processes each line (or block of lines) of the input data sequentially.
 // It maintains the original line order in the output.
 // Returns a boolean indicating if any encryption/decryption was performed and any error encountered.
 //
@@ -25,11 +25,17 @@ func (e *Encryptor) processLinesExperiments(reader io.Reader, writer io.Writer)
 		lines = append(lines, scanner.Text())
 
 		if strings.Contains(scanner.Text(), e.Directives.Encrypt) ||
-			strings.Contains(scanner.Text(), e.Directives.Decrypt) {
+			strings.Contains(scanner.Text(), e.Directives.Decrypt) ||
+			(e.Directives.Begin != "" && strings.Contains(scanner.Text(), e.Directives.Begin)) ||
+			(e.Directives.End != "" && strings.Contains(scanner.Text(), e.Directives.End)) {
 			anyFound = true
 		}
 	}
 
+	if err := scanner.Err(); err != nil {
+		return false, fmt.Errorf("%w: scanning error: %w", ErrProcessing, err)
+	}
+
 	if !anyFound {
 		// No directives found, return input as-is
 		for _, line := range lines {
@@ -37,73 +43,23 @@ func (e *Encryptor) processLinesExperiments(reader io.Reader, writer io.Writer)
 				return false, fmt.Errorf("%w: writing error: %w", ErrProcessing, err)
 			}
 		}
-	}
 
-	if err := scanner.Err(); err != nil {
-		return false, fmt.Errorf("%w: scanning error: %w", ErrProcessing, err)
+		return false, nil
 	}
 
-	wasPartOfPrevious := make([]bool, len(lines))
+	// Group lines into units, a standalone encrypt directive also takes the line following it
+	units, err := e.groupLines(lines, true)
+	if err != nil {
+		return false, err
+	}
 
 	var anyProcessed bool
 
-	for idx := range lines {
-		// Skip if this line was processed as part of previous directive
-		if wasPartOfPrevious[idx] {
-			continue
-		}
-
-		var (
-			result       string
-			wasProcessed bool
-		)
-
-		line := lines[idx]
-
+	for _, unit := range units {
 		// Process based on operation type and directives
-		switch {
-		case e.Operation == Encrypt:
-			switch {
-			case strings.TrimSpace(line) == e.Directives.Encrypt && idx+1 < len(lines):
-				nextLine := lines[idx+1]
-				contentToEncrypt := line + "\n" + nextLine
-
-				encryptedLine, err := e.encryptData([]byte(contentToEncrypt))
-				if err != nil {
-					return false, err
-				}
-
-				result = fmt.Sprintf("%s: %s", e.Directives.Decrypt, string(encryptedLine))
-				wasProcessed = true
-				// Mark next line as processed
-				wasPartOfPrevious[idx+1] = true
-
-			case strings.HasSuffix(line, e.Directives.Encrypt):
-				encryptedLine, err := e.encryptData([]byte(line))
-				if err != nil {
-					return false, err
-				}
-
-				result = fmt.Sprintf("%s: %s", e.Directives.Decrypt, string(encryptedLine))
-				wasProcessed = true
-
-			default:
-				result = line
-			}
-
-		case e.Operation == Decrypt && strings.HasPrefix(line, e.Directives.Decrypt+": "):
-			encryptedData := strings.TrimPrefix(line, e.Directives.Decrypt+": ")
-
-			decryptedLine, err := e.decryptData([]byte(encryptedData))
-			if err != nil {
-				return false, err
-			}
-
-			result = string(decryptedLine)
-			wasProcessed = true
-
-		default:
-			result = line
+		result, wasProcessed, err := e.processUnit(unit)
+		if err != nil {
+			return false, err
 		}
 
 		if _, err := fmt.Fprintln(writer, result); err != nil {
@@ -0,0 +1,99 @@
+package encrypt
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"io"
+	"strings"
+)
+
+// processINI encrypts or decrypts the selected values of an INI file.
+// A value is addressed by its section and key, as in `database.password`, and keys before any section by their key.
+// Comments, including those after a value, blank lines and the layout of each line are kept, only the values change.
+// Returns true if any value was encrypted or decrypted.
+func (e *Encryptor) processINI(reader io.Reader, writer io.Writer) (bool, error) {
+	var (
+		section      string
+		anyProcessed bool
+	)
+
+	buffered := bufio.NewReader(reader)
+
+	for {
+		text, err := buffered.ReadString('\n')
+		if err != nil && !errors.Is(err, io.EOF) {
+			return false, fmt.Errorf("reading input: %w", err)
+		}
+
+		if text == "" {
+			return anyProcessed, nil
+		}
+
+		trimmed := strings.TrimSpace(text)
+
+		switch {
+		case trimmed == "", strings.HasPrefix(trimmed, ";"), strings.HasPrefix(trimmed, "#"):
+		case strings.HasPrefix(trimmed, "[") && strings.Contains(trimmed, "]"):
+			section = strings.TrimSpace(trimmed[1:strings.Index(trimmed, "]")])
+		case strings.ContainsAny(text, "=:"):
+			prefix, value, suffix := splitINIValue(text)
+
+			path := []string{strings.TrimSpace(prefix[:strings.IndexAny(prefix, "=:")])}
+			if section != "" {
+				path = append([]string{section}, path...)
+			}
+
+			replacement, processed, err := e.processRawValue(value, path)
+			if err != nil {
+				return false, err
+			}
+
+			text = prefix + replacement + suffix
+			anyProcessed = anyProcessed || processed
+		}
+
+		if _, err := io.WriteString(writer, text); err != nil {
+			return false, fmt.Errorf("%w: writing error: %w", ErrProcessing, err)
+		}
+	}
+}
+
+// splitINIValue splits a `key = value` line into the text up to the value, the value and the rest of the line,
+// which holds an inline comment, if any, and the line ending.
+// The first `=` or `:` separates the key from the value, and a `;` or `#` after a blank starts a comment.
+func splitINIValue(text string) (string, string, string) {
+	sep := strings.IndexAny(text, "=:") + 1
+	start := sep + len(text[sep:]) - len(strings.TrimLeft(text[sep:], " \t"))
+
+	value := strings.TrimRight(text[start:], " \t\r\n")
+
+	// A value that is only a comment is empty
+	if start > sep && value != "" && (value[0] == ';' || value[0] == '#') {
+		value = ""
+	}
+
+	value = strings.TrimRight(value[:iniCommentStart(value)], " \t")
+
+	return text[:start], value, text[start+len(value):]
+}
+
+// iniCommentStart returns the index of the inline comment of a value, or its length if there is none.
+// A value starting with a quote is not searched before its closing quote.
+func iniCommentStart(value string) int {
+	from := 0
+
+	if value != "" && (value[0] == '"' || value[0] == '\'') {
+		if end := strings.IndexByte(value[1:], value[0]); end >= 0 {
+			from = end + 2
+		}
+	}
+
+	for idx := max(from, 1); idx < len(value); idx++ {
+		if (value[idx] == ';' || value[idx] == '#') && (value[idx-1] == ' ' || value[idx-1] == '\t') {
+			return idx
+		}
+	}
+
+	return len(value)
+}
@@ -0,0 +1,52 @@
+package encrypt
+
+import (
+	"bytes"
+	"testing"
+)
+
+func TestINISelector(t *testing.T) {
+	t.Parallel()
+
+	selector, err := NewSelector(nil, "(?i)password")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	input := []byte("user = admin\n[database]\npassword = hunter2\n")
+
+	encryptor := Encryptor{Keyring: testKeyring(t, randomizedKeyLen), Mode: INI, Selector: selector, Parallel: 1}
+
+	encrypted := roundTrip(t, encryptor, input, input)
+
+	if !bytes.Contains(encrypted, []byte("user = admin")) || bytes.Contains(encrypted, []byte("hunter2")) {
+		t.Fatalf("unexpected selection: %s", encrypted)
+	}
+}
+
+func TestSplitINIValue(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		text  string
+		value string
+	}{
+		{text: "key = value\n", value: "value"},
+		{text: "key = value ; comment\n", value: "value"},
+		{text: "key = value\t# comment\r\n", value: "value"},
+		{text: "key=#fff\n", value: "#fff"},
+		{text: "key = a;b#c\n", value: "a;b#c"},
+		{text: "key = ; comment\n", value: ""},
+		{text: "key = \"a ; b\" ; comment\n", value: "\"a ; b\""},
+		{text: "key = it's ; comment\n", value: "it's"},
+		{text: "key: value\n", value: "value"},
+	}
+
+	for _, test := range tests {
+		prefix, value, suffix := splitINIValue(test.text)
+
+		if value != test.value || prefix+value+suffix != test.text {
+			t.Fatalf("splitting %q: got %q, %q, %q, want value %q", test.text, prefix, value, suffix, test.value)
+		}
+	}
+}
@@ -0,0 +1,269 @@
+package encrypt
+
+import (
+	"bufio"
+	"bytes"
+	"crypto/aes"
+	"encoding/base64"
+	"encoding/binary"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"regexp"
+	"strings"
+)
+
+// encodedEnvelope matches base64-encoded envelopes, which all start with the encoded magic and version.
+var encodedEnvelope = regexp.MustCompile(`R09DUlk[A-Za-z0-9+/]*={0,2}`)
+
+// EnvelopeInfo describes an envelope as far as it can be known without a key.
+type EnvelopeInfo struct {
+	// Version is the envelope format version
+	Version int `json:"version"`
+
+	// Mode names the encryption mode, e.g. deterministic or randomized
+	Mode string `json:"mode"`
+
+	// Algorithm names the construction the payload is sealed with
+	Algorithm string `json:"algorithm"`
+
+	// KeyID is the fingerprint of the key, as printed by keygen, empty for version 1 envelopes
+	KeyID string `json:"keyId,omitempty"`
+
+	// Bound reports whether the envelope is bound to the path of its file
+	Bound bool `json:"bound"`
+
+	// Size is the size of the envelope in bytes
+	Size int `json:"size"`
+
+	// TagSize is the size of the authentication tag, per segment for segmented envelopes
+	TagSize int `json:"tagSize,omitempty"`
+
+	// SegmentSize is the amount of plaintext per segment, for segmented envelopes
+	SegmentSize int `json:"segmentSize,omitempty"`
+
+	// Segments is the number of segments, for segmented envelopes
+	Segments int `json:"segments,omitempty"`
+
+	// PlaintextSize is the size of the plaintext, as it follows from the layout
+	PlaintextSize int `json:"plaintextSize"`
+
+	// KDF names the key derivation function of a passphrase envelope
+	KDF string `json:"kdf,omitempty"`
+
+	// Recipients is the number of recipients of a recipients envelope
+	Recipients int `json:"recipients,omitempty"`
+
+	// Inner describes the envelope wrapped by a passphrase or recipients envelope
+	Inner *EnvelopeInfo `json:"inner,omitempty"`
+}
+
+// LineInfo describes a base64-encoded envelope found on a line of text.
+type LineInfo struct {
+	// Line is the number of the line, starting at 1
+	Line int `json:"line"`
+
+	// Directive reports whether the line carries the decryption directive
+	Directive bool `json:"directive"`
+
+	EnvelopeInfo
+}
+
+// String returns the name of the mode.
+func (m envelopeMode) String() string {
+	switch m &^ modeFlagBound {
+	case modeDeterministic:
+		return "deterministic"
+	case modeRandomized:
+		return "randomized"
+	case modePassphrase:
+		return "passphrase"
+	case modeRecipients:
+		return "recipients"
+	case modeSegmented:
+		return "segmented"
+	case modeSegmentedDeterministic:
+		return "segmented-deterministic"
+	default:
+		return fmt.Sprintf("unknown (%d)", byte(m))
+	}
+}
+
+// HasEnvelopeMagic reports whether data starts like an envelope, even if it turns out to be a broken one.
+func HasEnvelopeMagic(data []byte) bool {
+	return bytes.HasPrefix(data, envelopeHeaderPrefix)
+}
+
+// Inspect describes the envelope held by data, which must be complete, without decrypting it.
+func Inspect(data []byte) (EnvelopeInfo, error) {
+	header, err := parseEnvelopeHeader(data)
+	if err != nil {
+		return EnvelopeInfo{}, err
+	}
+
+	info := EnvelopeInfo{
+		Version: int(header.version),
+		Mode:    header.mode.String(),
+		KeyID:   hex.EncodeToString(header.keyID),
+		Bound:   header.bound,
+		Size:    len(data),
+	}
+
+	body := len(data) - header.size()
+
+	switch header.mode {
+	case modeDeterministic:
+		info.Algorithm, info.TagSize = "AES-SIV", aes.BlockSize
+		info.PlaintextSize = body - aes.BlockSize
+	case modeRandomized:
+		info.Algorithm, info.TagSize = "AES-CTR + HMAC-SHA256", envelopeTagSize
+		info.PlaintextSize = body - aes.BlockSize - envelopeTagSize
+	case modeSegmented, modeSegmentedDeterministic:
+		err = inspectSegments(&info, header, data[header.size():])
+	case modePassphrase, modeRecipients:
+		err = inspectWrapped(&info, header, data)
+	}
+
+	if err != nil {
+		return EnvelopeInfo{}, err
+	}
+
+	if info.PlaintextSize < 0 {
+		return EnvelopeInfo{}, fmt.Errorf("%w: ciphertext too short", ErrProcessing)
+	}
+
+	return info, nil
+}
+
+// inspectSegments fills in the layout of a segmented envelope from its body.
+func inspectSegments(info *EnvelopeInfo, header envelopeHeader, body []byte) error {
+	const sizeFieldSize = 4
+
+	info.Algorithm, info.TagSize = "AES-256-GCM", segmentTagSize
+
+	preamble := sizeFieldSize
+
+	if header.mode == modeSegmentedDeterministic {
+		info.Algorithm = "AES-SIV"
+	} else {
+		preamble += segmentSaltSize
+	}
+
+	if len(body) < preamble {
+		return fmt.Errorf("%w: ciphertext too short", ErrProcessing)
+	}
+
+	info.SegmentSize = int(binary.BigEndian.Uint32(body))
+	segments := len(body) - preamble
+
+	if info.SegmentSize == 0 || info.SegmentSize > segmentMaxSize {
+		return fmt.Errorf("%w: invalid segment size %d", ErrProcessing, info.SegmentSize)
+	}
+
+	sealed := info.SegmentSize + segmentTagSize
+	info.Segments = (segments + sealed - 1) / sealed
+	info.PlaintextSize = segments - info.Segments*segmentTagSize
+
+	return nil
+}
+
+// inspectWrapped fills in the key material of a passphrase or recipients envelope, and the envelope it wraps.
+func inspectWrapped(info *EnvelopeInfo, header envelopeHeader, data []byte) error {
+	reader := bytes.NewReader(data[header.size():])
+
+	switch header.mode {
+	case modePassphrase:
+		params, err := readKDFParams(reader)
+		if err != nil {
+			return err
+		}
+
+		info.KDF = string(Argon2id)
+		if params.kdf == kdfScrypt {
+			info.KDF = string(Scrypt)
+		}
+
+		info.Algorithm = info.KDF + " key derivation"
+	case modeRecipients:
+		count, err := reader.ReadByte()
+		if err != nil {
+			return fmt.Errorf("%w: ciphertext too short", ErrProcessing)
+		}
+
+		info.Algorithm, info.Recipients = "X25519 + ChaCha20-Poly1305", int(count)
+	}
+
+	inner, err := innerEnvelope(header, data)
+	if err != nil {
+		return err
+	}
+
+	if _, err := parseInnerEnvelopeHeader(inner); err != nil {
+		return err
+	}
+
+	innerInfo, err := Inspect(inner)
+	if err != nil {
+		return err
+	}
+
+	info.Inner = &innerInfo
+	info.PlaintextSize = innerInfo.PlaintextSize
+
+	return nil
+}
+
+// InspectLines describes the base64-encoded envelopes within text, as produced by line and structured modes.
+// Text that merely looks like an envelope is ignored.
+func (e *Encryptor) InspectLines(reader io.Reader) ([]LineInfo, error) {
+	var infos []LineInfo
+
+	marker := e.Directives.Decrypt + ": "
+	scanner := bufio.NewScanner(reader)
+	scanner.Buffer(nil, segmentMaxSize)
+
+	for number := 1; scanner.Scan(); number++ {
+		line := scanner.Text()
+
+		for _, encoded := range encodedEnvelope.FindAllString(line, -1) {
+			data, err := base64.StdEncoding.DecodeString(encoded)
+			if err != nil {
+				continue
+			}
+
+			info, err := Inspect(data)
+			if err != nil {
+				continue
+			}
+
+			infos = append(infos, LineInfo{
+				Line:         number,
+				Directive:    strings.Contains(line, marker),
+				EnvelopeInfo: info,
+			})
+		}
+	}
+
+	if err := scanner.Err(); err != nil {
+		return nil, fmt.Errorf("%w: scanning error: %w", ErrProcessing, err)
+	}
+
+	return infos, nil
+}
+
+// PendingLines returns the numbers of the lines in text that are still marked for encryption,
+// either ending with the encryption directive or opening a block, starting at 1.
+func (e *Encryptor) PendingLines(text []byte) []int {
+	var pending []int
+
+	for idx, line := range strings.Split(string(text), "\n") {
+		line = strings.TrimSuffix(line, "\r")
+
+		if (e.Directives.Encrypt != "" && strings.HasSuffix(line, e.Directives.Encrypt)) ||
+			(e.Directives.Begin != "" && strings.TrimSpace(line) == e.Directives.Begin) {
+			pending = append(pending, idx+1)
+		}
+	}
+
+	return pending
+}
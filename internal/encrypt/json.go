@@ -0,0 +1,218 @@
+package encrypt
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+)
+
+// jsonFrame tracks the position within an open JSON object or array.
+type jsonFrame struct {
+	// object is set for objects and unset for arrays
+	object bool
+
+	// key is the key of the current object member
+	key string
+
+	// expectKey is set while an object waits for the key of its next member
+	expectKey bool
+
+	// index is the index of the current array element
+	index int
+}
+
+// segment returns the path segment of the current member or element.
+func (f *jsonFrame) segment() string {
+	if f.object {
+		return f.key
+	}
+
+	return strconv.Itoa(f.index)
+}
+
+// next advances the frame past a completed member or element.
+func (f *jsonFrame) next() {
+	if f.object {
+		f.expectKey = true
+	} else {
+		f.index++
+	}
+}
+
+// processJSON encrypts or decrypts the selected values of a JSON document.
+// Only the tokens of processed values are replaced, so key order, whitespace, formatting and escapes are kept.
+// Returns true if any value was encrypted or decrypted.
+//
+//nolint:gocognit // function complexity is acceptable
+func (e *Encryptor) processJSON(reader io.Reader, writer io.Writer) (bool, error) {
+	data, err := io.ReadAll(reader)
+	if err != nil {
+		return false, fmt.Errorf("reading input: %w", err)
+	}
+
+	decoder := json.NewDecoder(bytes.NewReader(data))
+	decoder.UseNumber()
+
+	var (
+		out          bytes.Buffer
+		stack        []*jsonFrame
+		copied       int
+		anyProcessed bool
+	)
+
+	for {
+		offset := decoder.InputOffset()
+
+		token, err := decoder.Token()
+		if errors.Is(err, io.EOF) && len(stack) == 0 {
+			break
+		}
+
+		if errors.Is(err, io.EOF) {
+			err = io.ErrUnexpectedEOF
+		}
+
+		if err != nil {
+			return false, fmt.Errorf("%w: parsing json: %w", ErrProcessing, err)
+		}
+
+		var top *jsonFrame
+		if len(stack) > 0 {
+			top = stack[len(stack)-1]
+		}
+
+		if delim, ok := token.(json.Delim); ok {
+			switch delim {
+			case '{', '[':
+				stack = append(stack, &jsonFrame{object: delim == '{', expectKey: delim == '{'})
+			case '}', ']':
+				stack = stack[:len(stack)-1]
+
+				if len(stack) > 0 {
+					stack[len(stack)-1].next()
+				}
+			}
+
+			continue
+		}
+
+		if top != nil && top.expectKey {
+			top.key, _ = token.(string)
+			top.expectKey = false
+
+			continue
+		}
+
+		path := make([]string, 0, len(stack))
+		for _, frame := range stack {
+			path = append(path, frame.segment())
+		}
+
+		// The token starts after any whitespace and separators following the previous one
+		start := int(offset) + len(data[offset:]) - len(bytes.TrimLeft(data[offset:], " \t\r\n,:"))
+		end := int(decoder.InputOffset())
+
+		replacement, processed, err := e.processJSONValue(token, data[start:end], path)
+		if err != nil {
+			return false, err
+		}
+
+		if processed {
+			out.Write(data[copied:start])
+			out.Write(replacement)
+
+			copied = end
+			anyProcessed = true
+		}
+
+		if top != nil {
+			top.next()
+		}
+	}
+
+	out.Write(data[copied:])
+
+	if _, err := writer.Write(out.Bytes()); err != nil {
+		return false, fmt.Errorf("%w: writing error: %w", ErrProcessing, err)
+	}
+
+	return anyProcessed, nil
+}
+
+// processJSONValue encrypts a selected value into a string, or decrypts an encrypted string.
+// The raw token is encrypted as written, escapes included, together with its type, so that numbers,
+// booleans and null keep their type and every value is restored byte for byte.
+// It returns the replacement token and whether the value was processed.
+func (e *Encryptor) processJSONValue(token json.Token, raw []byte, path []string) ([]byte, bool, error) {
+	switch e.Operation {
+	case Encrypt:
+		if !e.Selector.Matches(path) {
+			return nil, false, nil
+		}
+
+		if typed, ok := token.(string); ok && isEncryptedValue(typed) {
+			return nil, false, nil
+		}
+
+		encrypted, err := e.encryptValue(string(raw), jsonKind(token))
+		if err != nil {
+			return nil, false, fmt.Errorf("encrypting %q: %w", strings.Join(path, "."), err)
+		}
+
+		return []byte(strconv.Quote(encrypted)), true, nil
+	case Decrypt:
+		encrypted, ok := token.(string)
+		if !ok || !isEncryptedValue(encrypted) {
+			return nil, false, nil
+		}
+
+		value, kind, err := e.decryptValue(encrypted)
+		if err != nil {
+			return nil, false, fmt.Errorf("decrypting %q: %w", strings.Join(path, "."), err)
+		}
+
+		if err := checkJSONLiteral(value, kind); err != nil {
+			return nil, false, fmt.Errorf("decrypting %q: %w", strings.Join(path, "."), err)
+		}
+
+		return []byte(value), true, nil
+	}
+
+	return nil, false, nil
+}
+
+// jsonKind returns the type of a scalar token, empty for strings.
+func jsonKind(token json.Token) string {
+	switch token.(type) {
+	case json.Number:
+		return "number"
+	case bool:
+		return "bool"
+	case nil:
+		return "null"
+	default:
+		return ""
+	}
+}
+
+// checkJSONLiteral checks that a decrypted literal is a single JSON scalar of the given type,
+// so that splicing it into the document cannot change its structure.
+func checkJSONLiteral(literal, kind string) error {
+	decoder := json.NewDecoder(strings.NewReader(literal))
+	decoder.UseNumber()
+
+	token, err := decoder.Token()
+	if err != nil || !json.Valid([]byte(literal)) {
+		return fmt.Errorf("%w: decrypted value is not a json literal", ErrProcessing)
+	}
+
+	if _, ok := token.(json.Delim); ok || jsonKind(token) != kind {
+		return fmt.Errorf("%w: decrypted value is not a json literal of type %q", ErrProcessing, kind)
+	}
+
+	return nil
+}
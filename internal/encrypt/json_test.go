@@ -0,0 +1,82 @@
+package encrypt
+
+import (
+	"errors"
+	"strings"
+	"testing"
+)
+
+func TestJSONEscapes(t *testing.T) {
+	t.Parallel()
+
+	// Strings are restored as written, not as re-encoded by the json package
+	input := []byte(`{"name": "caf\u00e9 \/ \ud83d\ude00 <b>", "port": 5.0e+3}` + "\n")
+
+	encryptor := Encryptor{Keyring: testKeyring(t, randomizedKeyLen), Mode: JSON, Parallel: 1}
+
+	roundTrip(t, encryptor, input, input)
+}
+
+func TestJSONRejects(t *testing.T) {
+	t.Parallel()
+
+	encryptor := Encryptor{Keyring: testKeyring(t, 32), Mode: JSON, Parallel: 1}
+
+	encrypted, err := process(t, encryptor, Encrypt, []byte(`{"port": 5432, "name": "admin"}`))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	name := strings.TrimSuffix(strings.SplitAfter(string(encrypted), `"name": "`)[1], `"}`)
+
+	tests := []struct {
+		name  string
+		input string
+	}{
+		{name: "retyped", input: strings.Replace(string(encrypted), ":number", ":bool", 1)},
+		{name: "type dropped", input: strings.Replace(string(encrypted), ":number", "", 1)},
+		{name: "string injected as number", input: strings.Replace(string(encrypted), name, name+":number", 1)},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			t.Parallel()
+
+			if _, err := process(t, encryptor, Decrypt, []byte(test.input)); !errors.Is(err, ErrProcessing) {
+				t.Fatalf("got %v, want %v", err, ErrProcessing)
+			}
+		})
+	}
+}
+
+func TestCheckJSONLiteral(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		literal string
+		kind    string
+		valid   bool
+	}{
+		{literal: `"café"`, kind: "", valid: true},
+		{literal: `-1.5e3`, kind: "number", valid: true},
+		{literal: `true`, kind: "bool", valid: true},
+		{literal: `null`, kind: "null", valid: true},
+		{literal: `1`, kind: "", valid: false},
+		{literal: `"1"`, kind: "number", valid: false},
+		{literal: `{"admin": true}`, kind: "", valid: false},
+		{literal: `[1]`, kind: "number", valid: false},
+		{literal: `1, "admin": true`, kind: "number", valid: false},
+		{literal: `"a" "b"`, kind: "", valid: false},
+		{literal: `caf`, kind: "", valid: false},
+	}
+
+	for _, test := range tests {
+		t.Run(test.literal, func(t *testing.T) {
+			t.Parallel()
+
+			if err := checkJSONLiteral(test.literal, test.kind); (err == nil) != test.valid {
+				t.Fatalf("got error %v, want valid %t", err, test.valid)
+			}
+		})
+	}
+}
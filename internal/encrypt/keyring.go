@@ -0,0 +1,108 @@
+package encrypt
+
+import (
+	"bytes"
+	"fmt"
+	"strings"
+)
+
+// Key is a named piece of key material.
+type Key struct {
+	// Name identifies the key for humans, e.g. the entry name in a keyring file
+	Name string
+
+	// Material is the raw key
+	Material []byte
+}
+
+// Keyring holds the keys available to an Encryptor.
+// The primary key is used for encryption, while every key is a candidate for decryption.
+type Keyring struct {
+	keys    []Key
+	primary int
+}
+
+// NewKeyring creates a keyring from the given keys, marking the key named primary for encryption.
+// A keyring holding a single key may leave primary empty.
+func NewKeyring(keys []Key, primary string) (*Keyring, error) {
+	if len(keys) == 0 {
+		return nil, fmt.Errorf("%w: keyring is empty", ErrProcessing)
+	}
+
+	if primary == "" {
+		if len(keys) > 1 {
+			return nil, fmt.Errorf("%w: keyring with several keys requires a primary key", ErrProcessing)
+		}
+
+		return &Keyring{keys: keys}, nil
+	}
+
+	for idx, key := range keys {
+		if key.Name == primary {
+			return &Keyring{keys: keys, primary: idx}, nil
+		}
+	}
+
+	return nil, fmt.Errorf("%w: primary key %q not found in keyring", ErrProcessing, primary)
+}
+
+// Primary returns the key used for encryption.
+func (k *Keyring) Primary() Key {
+	return k.keys[k.primary]
+}
+
+// Keys returns all keys in the keyring.
+func (k *Keyring) Keys() []Key {
+	return k.keys
+}
+
+// candidates returns the keys that may decrypt a payload with the given header.
+// Version 2 envelopes are matched by key identifier, version 1 envelopes by key length.
+func (k *Keyring) candidates(header envelopeHeader) ([]Key, error) {
+	if k == nil {
+		return nil, fmt.Errorf("%w: data is encrypted with a key, but only a passphrase was supplied", ErrKeyMismatch)
+	}
+
+	length := randomizedKeyLen
+	if header.mode.deterministic() {
+		length = deterministicKeyLen
+	}
+
+	var (
+		keys     []Key
+		supplied []string
+	)
+
+	for _, key := range k.keys {
+		if len(header.keyID) == 0 {
+			if len(key.Material) == length {
+				keys = append(keys, key)
+			}
+
+			continue
+		}
+
+		keyID, err := deriveKeyID(key.Material)
+		if err != nil {
+			return nil, err
+		}
+
+		if bytes.Equal(keyID, header.keyID) {
+			return []Key{key}, nil
+		}
+
+		supplied = append(supplied, fmt.Sprintf("%x", keyID))
+	}
+
+	switch {
+	case len(header.keyID) != 0:
+		return nil, fmt.Errorf("%w: encrypted with key %x, you supplied key %s",
+			ErrKeyMismatch, header.keyID, strings.Join(supplied, ", "))
+	case len(keys) == 0 && header.mode.deterministic():
+		return nil, fmt.Errorf("%w: deterministic data requires 64-byte key (128 hex chars)", ErrProcessing)
+	case len(keys) == 0:
+		return nil, fmt.Errorf("%w: randomized data requires 32-byte key (64 hex chars)", ErrProcessing)
+	}
+
+	return keys, nil
+}
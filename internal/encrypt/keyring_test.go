@@ -0,0 +1,109 @@
+package encrypt
+
+import (
+	"bytes"
+	"errors"
+	"testing"
+)
+
+func TestNewKeyring(t *testing.T) {
+	t.Parallel()
+
+	first := Key{Name: "first", Material: testKey(t, deterministicKeyLen)}
+	second := Key{Name: "second", Material: testKey(t, deterministicKeyLen)}
+
+	tests := []struct {
+		name    string
+		keys    []Key
+		primary string
+		want    string
+	}{
+		{name: "single key", keys: []Key{first}, want: "first"},
+		{name: "named primary", keys: []Key{first, second}, primary: "second", want: "second"},
+		{name: "empty", keys: nil},
+		{name: "no primary", keys: []Key{first, second}},
+		{name: "unknown primary", keys: []Key{first, second}, primary: "third"},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			t.Parallel()
+
+			keyring, err := NewKeyring(test.keys, test.primary)
+
+			switch {
+			case test.want == "" && !errors.Is(err, ErrProcessing):
+				t.Fatalf("got %v, want %v", err, ErrProcessing)
+			case test.want == "":
+			case err != nil:
+				t.Fatal(err)
+			case keyring.Primary().Name != test.want || len(keyring.Keys()) != len(test.keys):
+				t.Fatalf("got primary %q of %d keys, want %q", keyring.Primary().Name, len(keyring.Keys()), test.want)
+			}
+		})
+	}
+}
+
+func TestKeyringKeyID(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		name          string
+		keyLen        int
+		deterministic bool
+	}{
+		{name: "randomized", keyLen: randomizedKeyLen},
+		{name: "deterministic", keyLen: deterministicKeyLen, deterministic: true},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			t.Parallel()
+
+			data := []byte("secret data")
+			encryptor := Encryptor{Keyring: testKeyring(t, test.keyLen), Mode: File, Deterministic: test.deterministic}
+
+			encrypted, err := process(t, encryptor, Encrypt, data)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			other := encryptor
+			other.Keyring = testKeyring(t, test.keyLen)
+
+			if _, err := process(t, other, Decrypt, encrypted); !errors.Is(err, ErrKeyMismatch) {
+				t.Fatalf("got %v, want %v", err, ErrKeyMismatch)
+			}
+
+			// A keyring finds the key by its identifier, whichever key is primary
+			keyring, err := NewKeyring([]Key{
+				{Name: "new", Material: other.Keyring.Primary().Material},
+				{Name: "old", Material: encryptor.Keyring.Primary().Material},
+			}, "new")
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			other.Keyring = keyring
+
+			decrypted, err := process(t, other, Decrypt, encrypted)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			if !bytes.Equal(decrypted, data) {
+				t.Fatal("decrypting with the keyring gave different data")
+			}
+
+			// Encryption uses the primary key
+			reencrypted, err := process(t, other, Encrypt, data)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			if _, err := process(t, encryptor, Decrypt, reencrypted); !errors.Is(err, ErrKeyMismatch) {
+				t.Fatalf("got %v, want %v", err, ErrKeyMismatch)
+			}
+		})
+	}
+}
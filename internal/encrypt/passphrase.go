@@ -0,0 +1,301 @@
+package encrypt
+
+import (
+	"bytes"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/binary"
+	"fmt"
+	"io"
+	"sync"
+
+	"golang.org/x/crypto/argon2"
+	"golang.org/x/crypto/scrypt"
+)
+
+// KDF names a memory-hard key derivation function for passphrases.
+type KDF string
+
+const (
+	// Argon2id derives keys with Argon2id (RFC 9106).
+	Argon2id KDF = "argon2id"
+
+	// Scrypt derives keys with scrypt (RFC 7914).
+	Scrypt KDF = "scrypt"
+)
+
+// kdf identifiers as stored in the envelope.
+const (
+	kdfArgon2id = byte(0x01)
+	kdfScrypt   = byte(0x02)
+)
+
+const (
+	kdfSaltSize = 16
+
+	// kdfParamsSize is the size of the parameter block following a passphrase header:
+	// [kdf | param1 (uint32) | param2 (uint32) | param3 | salt].
+	kdfParamsSize = 1 + 4 + 4 + 1 + kdfSaltSize
+
+	// Upper bounds on the cost parameters accepted during decryption,
+	// so that a crafted envelope cannot exhaust memory or CPU.
+	// Memory is capped at 1 GiB for both functions, scrypt using 128·N·r bytes.
+	kdfMaxMemory       = 1 << 30
+	argon2MaxTime      = 16
+	argon2MaxMemoryKiB = kdfMaxMemory >> 10
+	scryptMaxN         = 1 << 22
+	scryptMaxR         = 32
+	scryptMaxP         = 16
+
+	// saltDomain separates the derivation of deterministic salts from the derivation of keys.
+	saltDomain = "gocry/passphrase-salt:"
+)
+
+// kdfParams holds the key derivation parameters recorded in a passphrase envelope.
+type kdfParams struct {
+	// kdf identifies the key derivation function
+	kdf byte
+
+	// param1 is the Argon2id time cost or the scrypt N parameter
+	param1 uint32
+
+	// param2 is the Argon2id memory cost in KiB or the scrypt r parameter
+	param2 uint32
+
+	// param3 is the Argon2id parallelism or the scrypt p parameter
+	param3 byte
+
+	// salt is the KDF salt
+	salt []byte
+}
+
+// defaultKDFParams returns the recommended cost parameters for kdf.
+func defaultKDFParams(kdf KDF) (kdfParams, error) {
+	switch kdf {
+	case Argon2id:
+		return kdfParams{kdf: kdfArgon2id, param1: 3, param2: 64 << 10, param3: 4}, nil
+	case Scrypt:
+		return kdfParams{kdf: kdfScrypt, param1: 1 << 15, param2: 8, param3: 1}, nil
+	default:
+		return kdfParams{}, fmt.Errorf("%w: unsupported kdf %q", ErrProcessing, kdf)
+	}
+}
+
+// marshal encodes the parameters into their envelope representation.
+func (p kdfParams) marshal() []byte {
+	out := make([]byte, 0, kdfParamsSize)
+
+	out = append(out, p.kdf)
+	out = binary.BigEndian.AppendUint32(out, p.param1)
+	out = binary.BigEndian.AppendUint32(out, p.param2)
+	out = append(out, p.param3)
+
+	return append(out, p.salt...)
+}
+
+// readKDFParams reads and validates a parameter block from reader.
+func readKDFParams(reader io.Reader) (kdfParams, error) {
+	raw := make([]byte, kdfParamsSize)
+	if _, err := io.ReadFull(reader, raw); err != nil {
+		return kdfParams{}, fmt.Errorf("reading kdf parameters: %w", err)
+	}
+
+	params := kdfParams{
+		kdf:    raw[0],
+		param1: binary.BigEndian.Uint32(raw[1:5]),
+		param2: binary.BigEndian.Uint32(raw[5:9]),
+		param3: raw[9],
+		salt:   raw[10:],
+	}
+
+	var valid bool
+
+	switch params.kdf {
+	case kdfArgon2id:
+		valid = params.param1 > 0 && params.param1 <= argon2MaxTime &&
+			params.param2 > 0 && params.param2 <= argon2MaxMemoryKiB && params.param3 > 0
+	case kdfScrypt:
+		valid = params.param1 > 1 && params.param1 <= scryptMaxN && params.param1&(params.param1-1) == 0 &&
+			params.param2 > 0 && params.param2 <= scryptMaxR && params.param3 > 0 && params.param3 <= scryptMaxP &&
+			uint64(params.param1)*uint64(params.param2)*128 <= kdfMaxMemory //nolint:mnd // scrypt block size
+	default:
+		return kdfParams{}, fmt.Errorf("%w: unsupported kdf %d", ErrProcessing, params.kdf)
+	}
+
+	if !valid {
+		return kdfParams{}, fmt.Errorf("%w: invalid kdf parameters", ErrProcessing)
+	}
+
+	return params, nil
+}
+
+// derive runs the key derivation function over secret.
+func (p kdfParams) derive(secret []byte, length int) ([]byte, error) {
+	switch p.kdf {
+	case kdfArgon2id:
+		return argon2.IDKey(secret, p.salt, p.param1, p.param2, p.param3, uint32(length)), nil //nolint:gosec // bounded
+	case kdfScrypt:
+		key, err := scrypt.Key(secret, p.salt, int(p.param1), int(p.param2), int(p.param3), length)
+		if err != nil {
+			return nil, fmt.Errorf("deriving key: %w", err)
+		}
+
+		return key, nil
+	default:
+		return nil, fmt.Errorf("%w: unsupported kdf %d", ErrProcessing, p.kdf)
+	}
+}
+
+// Passphrase derives encryption keys from a passphrase using a memory-hard KDF.
+// A single 64-byte key is derived per salt: deterministic mode uses all of it,
+// randomized mode its first 32 bytes. Derived keys are cached, so that line mode
+// only pays the derivation cost once per salt.
+type Passphrase struct {
+	secret []byte
+	kdf    KDF
+
+	mutex sync.Mutex
+	keys  map[string][]byte
+	salt  []byte
+
+	// deterministicSalt is the salt of deterministic mode, derived once
+	deterministicSalt []byte
+}
+
+// NewPassphrase creates a passphrase deriving keys with the given KDF.
+func NewPassphrase(secret []byte, kdf KDF) (*Passphrase, error) {
+	if len(secret) == 0 {
+		return nil, fmt.Errorf("%w: empty passphrase", ErrProcessing)
+	}
+
+	if kdf == "" {
+		kdf = Argon2id
+	}
+
+	if _, err := defaultKDFParams(kdf); err != nil {
+		return nil, err
+	}
+
+	return &Passphrase{secret: secret, kdf: kdf, keys: make(map[string][]byte)}, nil
+}
+
+// key derives (or returns the cached) key for params.
+func (p *Passphrase) key(params kdfParams) ([]byte, error) {
+	id := string(params.marshal())
+
+	p.mutex.Lock()
+	defer p.mutex.Unlock()
+
+	if key, ok := p.keys[id]; ok {
+		return key, nil
+	}
+
+	key, err := params.derive(p.secret, deterministicKeyLen)
+	if err != nil {
+		return nil, err
+	}
+
+	p.keys[id] = key
+
+	return key, nil
+}
+
+// saltFor returns the salt of deterministic mode for params.
+// The salt is stored in clear, so it is derived from the passphrase through the KDF itself,
+// under a fixed salt and a domain-separated input: testing a guess against it costs as much as against the key.
+func (p *Passphrase) saltFor(params kdfParams) ([]byte, error) {
+	p.mutex.Lock()
+	defer p.mutex.Unlock()
+
+	if p.deterministicSalt != nil {
+		return p.deterministicSalt, nil
+	}
+
+	domain := sha256.Sum256([]byte(saltDomain + string(p.kdf)))
+	params.salt = domain[:kdfSaltSize]
+
+	salt, err := params.derive(append([]byte(saltDomain), p.secret...), kdfSaltSize)
+	if err != nil {
+		return nil, err
+	}
+
+	p.deterministicSalt = salt
+
+	return salt, nil
+}
+
+// prefix returns the key for encrypting with the given mode, together with the
+// passphrase header and parameter block that must precede the inner envelope.
+// Deterministic mode derives its salt from the passphrase so that output stays stable,
+// randomized mode draws a random salt once per Passphrase.
+func (p *Passphrase) prefix(mode envelopeMode) ([]byte, []byte, error) {
+	params, err := defaultKDFParams(p.kdf)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	if mode.deterministic() {
+		if params.salt, err = p.saltFor(params); err != nil {
+			return nil, nil, err
+		}
+	} else {
+		p.mutex.Lock()
+
+		if p.salt == nil {
+			p.salt = make([]byte, kdfSaltSize)
+			if _, err := io.ReadFull(rand.Reader, p.salt); err != nil {
+				p.mutex.Unlock()
+
+				return nil, nil, fmt.Errorf("generating salt: %w", err)
+			}
+		}
+
+		params.salt = p.salt
+
+		p.mutex.Unlock()
+	}
+
+	key, err := p.key(params)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	if mode.randomized() {
+		key = key[:randomizedKeyLen]
+	}
+
+	header, err := newEnvelopeHeader(modePassphrase, key)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	return key, append(header, params.marshal()...), nil
+}
+
+// keyring reads the parameter block following a passphrase header from reader
+// and returns a keyring holding the key derived from them.
+func (p *Passphrase) keyring(header envelopeHeader, reader io.Reader) (*Keyring, error) {
+	params, err := readKDFParams(reader)
+	if err != nil {
+		return nil, err
+	}
+
+	derived, err := p.key(params)
+	if err != nil {
+		return nil, err
+	}
+
+	// The header identifies either the full key (deterministic) or its randomized prefix.
+	for _, key := range [][]byte{derived, derived[:randomizedKeyLen]} {
+		keyID, err := deriveKeyID(key)
+		if err != nil {
+			return nil, err
+		}
+
+		if bytes.Equal(keyID, header.keyID) {
+			return NewKeyring([]Key{{Name: "passphrase", Material: key}}, "")
+		}
+	}
+
+	return nil, fmt.Errorf("%w: wrong passphrase", ErrKeyMismatch)
+}
@@ -0,0 +1,174 @@
+package encrypt
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"errors"
+	"testing"
+)
+
+// testPassphrase returns a passphrase deriving keys with kdf.
+func testPassphrase(t *testing.T, secret string, kdf KDF) *Passphrase {
+	t.Helper()
+
+	passphrase, err := NewPassphrase([]byte(secret), kdf)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return passphrase
+}
+
+func TestPassphraseRoundTrip(t *testing.T) {
+	t.Parallel()
+
+	lines := []byte("user=admin\npassword=hunter2 ### DIRECTIVE: ENCRYPT\n")
+
+	tests := []struct {
+		name          string
+		kdf           KDF
+		mode          Mode
+		deterministic bool
+	}{
+		{name: "argon2id file deterministic", kdf: Argon2id, mode: File, deterministic: true},
+		{name: "argon2id file randomized", kdf: Argon2id, mode: File},
+		{name: "scrypt file deterministic", kdf: Scrypt, mode: File, deterministic: true},
+		{name: "scrypt line randomized", kdf: Scrypt, mode: Line},
+		{name: "argon2id line deterministic", kdf: Argon2id, mode: Line, deterministic: true},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			t.Parallel()
+
+			encryptor := Encryptor{
+				Passphrase:    testPassphrase(t, "correct horse battery staple", test.kdf),
+				Mode:          test.mode,
+				Directives:    testDirectives,
+				Parallel:      1,
+				Deterministic: test.deterministic,
+			}
+
+			encrypted := roundTrip(t, encryptor, lines, lines)
+
+			if bytes.Contains(encrypted, []byte("hunter2")) {
+				t.Fatalf("ciphertext holds the plaintext: %q", encrypted)
+			}
+		})
+	}
+}
+
+func TestPassphraseDeterministicSalt(t *testing.T) {
+	t.Parallel()
+
+	const secret = "correct horse battery staple"
+
+	data := []byte("secret data")
+
+	encrypt := func(kdf KDF) []byte {
+		encrypted, err := process(t, Encryptor{
+			Passphrase:    testPassphrase(t, secret, kdf),
+			Mode:          File,
+			Deterministic: true,
+		}, Encrypt, data)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		return encrypted
+	}
+
+	first, second := encrypt(Argon2id), encrypt(Argon2id)
+	if !bytes.Equal(first, second) {
+		t.Fatal("deterministic passphrase encryption is not stable")
+	}
+
+	salt := func(encrypted []byte) []byte {
+		offset := envelopeHeaderSize + kdfParamsSize - kdfSaltSize
+
+		return encrypted[offset : offset+kdfSaltSize]
+	}
+
+	if bytes.Equal(salt(first), salt(encrypt(Scrypt))) {
+		t.Fatal("the salt does not depend on the kdf")
+	}
+
+	// A salt computable with a fast hash would let guesses skip the KDF
+	for _, kdf := range []KDF{Argon2id, Scrypt} {
+		guess := sha256.Sum256(append([]byte("gocry/passphrase-salt:"+string(kdf)+":"), secret...))
+		if bytes.Equal(salt(first), guess[:kdfSaltSize]) {
+			t.Fatalf("the salt is a plain hash of the passphrase with %s", kdf)
+		}
+	}
+}
+
+func TestPassphraseRejects(t *testing.T) {
+	t.Parallel()
+
+	data := []byte("secret data")
+
+	encryptor := Encryptor{Passphrase: testPassphrase(t, "right", Argon2id), Mode: File, Deterministic: true}
+
+	encrypted, err := process(t, encryptor, Encrypt, data)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	t.Run("wrong passphrase", func(t *testing.T) {
+		t.Parallel()
+
+		wrong := Encryptor{Passphrase: testPassphrase(t, "wrong", Argon2id), Mode: File}
+
+		if _, err := process(t, wrong, Decrypt, encrypted); !errors.Is(err, ErrKeyMismatch) {
+			t.Fatalf("got %v, want %v", err, ErrKeyMismatch)
+		}
+	})
+
+	t.Run("tampered salt", func(t *testing.T) {
+		t.Parallel()
+
+		tampered := bytes.Clone(encrypted)
+		tampered[envelopeHeaderSize+kdfParamsSize-1] ^= 1
+
+		if _, err := process(t, encryptor, Decrypt, tampered); err == nil {
+			t.Fatal("decrypting with a tampered salt succeeded")
+		}
+	})
+}
+
+func TestReadKDFParams(t *testing.T) {
+	t.Parallel()
+
+	salt := make([]byte, kdfSaltSize)
+
+	tests := []struct {
+		name   string
+		params kdfParams
+		valid  bool
+	}{
+		{name: "argon2id default", params: kdfParams{kdf: kdfArgon2id, param1: 3, param2: 64 << 10, param3: 4}, valid: true},
+		{name: "argon2id 1 GiB", params: kdfParams{kdf: kdfArgon2id, param1: 1, param2: 1 << 20, param3: 1}, valid: true},
+		{name: "argon2id 4 GiB", params: kdfParams{kdf: kdfArgon2id, param1: 1, param2: 4 << 20, param3: 1}},
+		{name: "argon2id time cost", params: kdfParams{kdf: kdfArgon2id, param1: 64, param2: 64 << 10, param3: 1}},
+		{name: "argon2id zero", params: kdfParams{kdf: kdfArgon2id, param1: 0, param2: 64 << 10, param3: 1}},
+		{name: "scrypt default", params: kdfParams{kdf: kdfScrypt, param1: 1 << 15, param2: 8, param3: 1}, valid: true},
+		{name: "scrypt 1 GiB", params: kdfParams{kdf: kdfScrypt, param1: 1 << 20, param2: 8, param3: 1}, valid: true},
+		{name: "scrypt 2 GiB", params: kdfParams{kdf: kdfScrypt, param1: 1 << 20, param2: 16, param3: 1}},
+		{name: "scrypt N not a power of 2", params: kdfParams{kdf: kdfScrypt, param1: 3 << 10, param2: 8, param3: 1}},
+		{name: "scrypt parallelism", params: kdfParams{kdf: kdfScrypt, param1: 1 << 15, param2: 8, param3: 255}},
+		{name: "unknown kdf", params: kdfParams{kdf: 0x7f, param1: 1, param2: 1, param3: 1}},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			t.Parallel()
+
+			test.params.salt = salt
+
+			_, err := readKDFParams(bytes.NewReader(test.params.marshal()))
+			if (err == nil) != test.valid {
+				t.Fatalf("got error %v, want valid %t", err, test.valid)
+			}
+		})
+	}
+}
@@ -5,19 +5,23 @@ import (
 	"errors"
 	"fmt"
 	"io"
-	"strings"
 	"sync"
 )
 
-// ErrProcessing indicates an error during processing.
-var ErrProcessing = errors.New("processing error")
+var (
+	// ErrProcessing indicates an error during processing.
+	ErrProcessing = errors.New("processing error")
+
+	// ErrKeyMismatch indicates that the data was encrypted with a different key than the one supplied.
+	ErrKeyMismatch = errors.New("key mismatch")
+)
 
 const (
 	deterministicKeyLen = 64
 	randomizedKeyLen    = 32
 )
 
-// processLines processes each line of the input data in parallel when possible.
+// processLines processes each line (or block of lines) of the input data in parallel when possible.
 // It maintains the original line order in the output while leveraging parallel processing.
 // Returns a boolean indicating if any encryption/decryption was performed and any error encountered.
 //
@@ -35,14 +39,20 @@ func (e *Encryptor) processLines(reader io.Reader, writer io.Writer, parallel in
 		return false, fmt.Errorf("%w: scanning error: %w", ErrProcessing, err)
 	}
 
+	// Group lines into units, so that blocks are processed as one
+	units, err := e.groupLines(lines, false)
+	if err != nil {
+		return false, err
+	}
+
 	// Initialize result storage and channels
-	results := make([]string, len(lines))
+	results := make([]string, len(units))
 	numWorkers := parallel
 	workChan := make(chan int)
 	errChan := make(chan error)
 
-	// Track processing status per line
-	processedStatus := make([]bool, len(lines))
+	// Track processing status per unit
+	processedStatus := make([]bool, len(units))
 
 	var waitGroup sync.WaitGroup
 
@@ -54,41 +64,12 @@ func (e *Encryptor) processLines(reader io.Reader, writer io.Writer, parallel in
 			defer waitGroup.Done()
 
 			for idx := range workChan {
-				line := lines[idx]
-
-				var (
-					result       string
-					wasProcessed bool
-				)
-
-				// Process each line based on operation type and directives
-				switch {
-				case e.Operation == Encrypt && strings.HasSuffix(line, e.Directives.Encrypt):
-					encryptedLine, err := e.encryptData([]byte(line))
-					if err != nil {
-						errChan <- err
+				// Process each unit based on operation type and directives
+				result, wasProcessed, err := e.processUnit(units[idx])
+				if err != nil {
+					errChan <- err
 
-						return
-					}
-
-					result = fmt.Sprintf("%s: %s", e.Directives.Decrypt, string(encryptedLine))
-					wasProcessed = true
-
-				case e.Operation == Decrypt && strings.HasPrefix(line, e.Directives.Decrypt+": "):
-					encryptedData := strings.TrimPrefix(line, e.Directives.Decrypt+": ")
-
-					decryptedLine, err := e.decryptData([]byte(encryptedData))
-					if err != nil {
-						errChan <- err
-
-						return
-					}
-
-					result = string(decryptedLine)
-					wasProcessed = true
-
-				default:
-					result = line
+					return
 				}
 
 				results[idx] = result
@@ -99,7 +80,7 @@ func (e *Encryptor) processLines(reader io.Reader, writer io.Writer, parallel in
 
 	// Distribute work to workers
 	go func() {
-		for i := range lines {
+		for i := range units {
 			workChan <- i
 		}
 
@@ -146,8 +127,30 @@ func (e *Encryptor) processLines(reader io.Reader, writer io.Writer, parallel in
 func (e *Encryptor) processWholeFile(reader io.Reader, writer io.Writer) (bool, error) {
 	switch e.Operation {
 	case Encrypt:
+		key, prefix, err := e.encryptionKey()
+		if err != nil {
+			return false, err
+		}
+
+		binding, err := e.sealBinding()
+		if err != nil {
+			return false, err
+		}
+
+		if _, err := writer.Write(prefix); err != nil {
+			return false, fmt.Errorf("writing header: %w", err)
+		}
+
+		if e.Deterministic && e.Chunked {
+			return true, encryptSegmented(modeSegmentedDeterministic, key, binding, reader, writer, e.Parallel)
+		}
+
 		if e.Deterministic {
-			header := newEnvelopeHeader(modeDeterministic)
+			header, err := newEnvelopeHeader(modeDeterministic.bind(binding), key)
+			if err != nil {
+				return false, err
+			}
+
 			if _, err := writer.Write(header); err != nil {
 				return false, fmt.Errorf("writing header: %w", err)
 			}
@@ -157,7 +160,7 @@ func (e *Encryptor) processWholeFile(reader io.Reader, writer io.Writer) (bool,
 				return false, fmt.Errorf("reading input: %w", err)
 			}
 
-			out, err := e.encryptDeterministic(buf)
+			out, err := encryptDeterministic(key, buf, binding)
 			if err != nil {
 				return false, err
 			}
@@ -167,50 +170,67 @@ func (e *Encryptor) processWholeFile(reader io.Reader, writer io.Writer) (bool,
 			return true, err //nolint:wrapcheck // error does not need wrapping
 		}
 
-		return true, e.encryptStream(reader, writer)
+		return true, encryptSegmented(modeSegmented, key, binding, reader, writer, e.Parallel)
 	case Decrypt:
-		header := make([]byte, envelopeHeaderSize)
-		if _, err := io.ReadFull(reader, header); err != nil {
-			return false, fmt.Errorf("reading header: %w", err)
-		}
-
-		mode, err := parseEnvelopeHeader(header)
+		header, raw, err := readEnvelopeHeader(reader)
 		if err != nil {
 			return false, err
 		}
 
-		switch mode {
-		case modeDeterministic:
-			if len(e.Key) != deterministicKeyLen {
-				return false, fmt.Errorf("%w: deterministic data requires 64-byte key (128 hex chars)", ErrProcessing)
-			}
+		keyring := e.Keyring
 
-			e.Deterministic = true
-
-			buf, err := io.ReadAll(reader)
+		if header.mode.wraps() {
+			keyring, err = e.unwrapKeyring(header, reader)
 			if err != nil {
-				return false, fmt.Errorf("reading ciphertext: %w", err)
+				return false, err
 			}
 
-			out, err := e.decryptDeterministic(buf)
+			header, raw, err = readEnvelopeHeader(reader)
 			if err != nil {
 				return false, err
 			}
 
-			_, err = writer.Write(out)
-
-			return true, err //nolint:wrapcheck // error does not need wrapping
-		case modeRandomized:
-			if len(e.Key) != randomizedKeyLen {
-				return false, fmt.Errorf("%w: randomized data requires 32-byte key (64 hex chars)", ErrProcessing)
+			if header.mode.wraps() {
+				return false, fmt.Errorf("%w: nested wrapping envelope", ErrProcessing)
 			}
+		}
+
+		keys, err := keyring.candidates(header)
+		if err != nil {
+			return false, err
+		}
+
+		binding, err := e.openBinding(header)
+		if err != nil {
+			return false, err
+		}
+
+		e.Deterministic = header.mode.deterministic()
+
+		// A single candidate key lets randomized data be streamed,
+		// anything else has to be held in memory to be retried.
+		switch {
+		case header.mode.segmented() && len(keys) == 1:
+			err := decryptSegmented(keys[0].Material, binding, reader, writer, raw, e.Parallel)
 
-			e.Deterministic = false
+			return true, e.explainBinding(header, err)
+		case header.mode == modeRandomized && !header.bound && len(keys) == 1:
+			return true, decryptStream(keys[0].Material, reader, writer, raw)
+		}
 
-			return true, e.decryptStream(reader, writer, header)
-		default:
-			return false, fmt.Errorf("%w: invalid header mode", ErrProcessing)
+		buf, err := io.ReadAll(reader)
+		if err != nil {
+			return false, fmt.Errorf("reading ciphertext: %w", err)
 		}
+
+		out, err := openEnvelope(keyring, header, append(raw, buf...), binding)
+		if err != nil {
+			return false, e.explainBinding(header, err)
+		}
+
+		_, err = writer.Write(out)
+
+		return true, err //nolint:wrapcheck // error does not need wrapping
 	}
 
 	return false, fmt.Errorf("%w: invalid operation", ErrProcessing)
@@ -3,6 +3,7 @@ package encrypt
 import (
 	"bytes"
 	"crypto/sha256"
+	"encoding/hex"
 	"fmt"
 	"io"
 
@@ -10,9 +11,11 @@ import (
 )
 
 const (
-	envelopeMagic   = "GOCRY"
-	envelopeVersion = byte(1)
-	envelopeTagSize = sha256.Size
+	envelopeMagic         = "GOCRY"
+	envelopeVersionLegacy = byte(1)
+	envelopeVersion       = byte(2)
+	envelopeTagSize       = sha256.Size
+	envelopeKeyIDSize     = 8
 )
 
 type envelopeMode byte
@@ -20,14 +23,101 @@ type envelopeMode byte
 const (
 	modeDeterministic envelopeMode = 0x01
 	modeRandomized    envelopeMode = 0x02
+
+	// modePassphrase wraps a deterministic or randomized envelope whose key is derived from a passphrase.
+	// Layout: [header | kdf parameters | inner envelope].
+	modePassphrase envelopeMode = 0x03
+
+	// modeRecipients wraps a randomized envelope whose data key is encrypted for X25519 recipients.
+	// Layout: [header | recipient count | recipient stanzas | inner envelope].
+	modeRecipients envelopeMode = 0x04
+
+	// modeSegmented is a randomized envelope sealed in independently authenticated segments,
+	// so that whole-file data can be verified chunk by chunk while streaming.
+	// Layout: [header | segment size | salt | segments].
+	modeSegmented envelopeMode = 0x05
+
+	// modeSegmentedDeterministic is a deterministic envelope sealed in AES-SIV segments,
+	// so that large files can be processed with bounded memory.
+	// Layout: [header | segment size | segments].
+	modeSegmentedDeterministic envelopeMode = 0x06
+
+	// modeFlagBound marks an envelope whose payload authenticates the path of the file it belongs to.
+	// It is combined with the mode of the envelope doing the encryption.
+	modeFlagBound envelopeMode = 0x80
 )
 
+// bind returns the mode flagged as bound when binding is set.
+func (m envelopeMode) bind(binding []byte) envelopeMode {
+	if binding != nil {
+		return m | modeFlagBound
+	}
+
+	return m
+}
+
+// wraps reports whether the mode wraps an inner envelope.
+func (m envelopeMode) wraps() bool {
+	return m == modePassphrase || m == modeRecipients
+}
+
+// deterministic reports whether the mode is one of the deterministic payload modes.
+func (m envelopeMode) deterministic() bool {
+	return m == modeDeterministic || m == modeSegmentedDeterministic
+}
+
+// segmented reports whether the mode seals its payload in segments.
+func (m envelopeMode) segmented() bool {
+	return m == modeSegmented || m == modeSegmentedDeterministic
+}
+
+// randomized reports whether the mode is one of the randomized (non-deterministic) payload modes.
+func (m envelopeMode) randomized() bool {
+	return m == modeRandomized || m == modeSegmented
+}
+
 //nolint:gochecknoglobals // these globals are acceptable
 var envelopeHeaderPrefix = []byte(envelopeMagic)
 
-const envelopeHeaderSize = len(envelopeMagic) + 2
+const (
+	// envelopeHeaderSizeLegacy is the size of a version 1 header: [magic | version | mode].
+	envelopeHeaderSizeLegacy = len(envelopeMagic) + 2
+
+	// envelopeHeaderSize is the size of a version 2 header: [magic | version | mode | key ID].
+	envelopeHeaderSize = envelopeHeaderSizeLegacy + envelopeKeyIDSize
+)
+
+// envelopeHeader is the parsed form of the header that prefixes every ciphertext.
+type envelopeHeader struct {
+	// version is the envelope format version
+	version byte
+
+	// mode is the encryption mode the payload was produced with
+	mode envelopeMode
+
+	// keyID identifies the key used for encryption, empty for version 1 envelopes
+	keyID []byte
+
+	// bound reports whether the payload authenticates the path of its file
+	bound bool
+}
+
+// size returns the number of bytes the header occupies on the wire.
+func (h envelopeHeader) size() int {
+	if h.version == envelopeVersionLegacy {
+		return envelopeHeaderSizeLegacy
+	}
+
+	return envelopeHeaderSize
+}
+
+// newEnvelopeHeader returns a version 2 header for the given mode, embedding the identifier of key.
+func newEnvelopeHeader(mode envelopeMode, key []byte) ([]byte, error) {
+	keyID, err := deriveKeyID(key)
+	if err != nil {
+		return nil, err
+	}
 
-func newEnvelopeHeader(mode envelopeMode) []byte {
 	header := make([]byte, envelopeHeaderSize)
 
 	copy(header, envelopeHeaderPrefix)
@@ -35,30 +125,102 @@ func newEnvelopeHeader(mode envelopeMode) []byte {
 	header[len(envelopeHeaderPrefix)] = envelopeVersion
 	header[len(envelopeHeaderPrefix)+1] = byte(mode)
 
-	return header
+	copy(header[envelopeHeaderSizeLegacy:], keyID)
+
+	return header, nil
 }
 
-func parseEnvelopeHeader(header []byte) (envelopeMode, error) {
-	if len(header) != envelopeHeaderSize {
-		return 0, fmt.Errorf("%w: header too short", ErrProcessing)
+// parseEnvelopeHeader parses the header at the start of data.
+// Both version 1 and version 2 headers are accepted; data may extend past the header.
+func parseEnvelopeHeader(data []byte) (envelopeHeader, error) {
+	if len(data) < envelopeHeaderSizeLegacy {
+		return envelopeHeader{}, fmt.Errorf("%w: header too short", ErrProcessing)
+	}
+
+	if !bytes.Equal(data[:len(envelopeHeaderPrefix)], envelopeHeaderPrefix) {
+		return envelopeHeader{}, fmt.Errorf("%w: invalid header magic", ErrProcessing)
+	}
+
+	mode := envelopeMode(data[len(envelopeHeaderPrefix)+1])
+
+	header := envelopeHeader{
+		version: data[len(envelopeHeaderPrefix)],
+		mode:    mode &^ modeFlagBound,
+		bound:   mode&modeFlagBound != 0,
 	}
 
-	if !bytes.Equal(header[:len(envelopeHeaderPrefix)], envelopeHeaderPrefix) {
-		return 0, fmt.Errorf("%w: invalid header magic", ErrProcessing)
+	switch header.version {
+	case envelopeVersionLegacy:
+	case envelopeVersion:
+		if len(data) < envelopeHeaderSize {
+			return envelopeHeader{}, fmt.Errorf("%w: header too short", ErrProcessing)
+		}
+
+		header.keyID = data[envelopeHeaderSizeLegacy:envelopeHeaderSize]
+	default:
+		return envelopeHeader{}, fmt.Errorf("%w: unsupported version %d", ErrProcessing, header.version)
 	}
 
-	version := header[len(envelopeHeaderPrefix)]
-	if version != envelopeVersion {
-		return 0, fmt.Errorf("%w: unsupported version %d", ErrProcessing, version)
+	if header.bound && (header.version == envelopeVersionLegacy || header.mode.wraps()) {
+		return envelopeHeader{}, fmt.Errorf("%w: unsupported mode %d", ErrProcessing, mode)
 	}
 
-	mode := envelopeMode(header[len(envelopeHeaderPrefix)+1])
-	switch mode {
+	switch header.mode {
 	case modeDeterministic, modeRandomized:
-		return mode, nil
+		return header, nil
+	case modePassphrase, modeRecipients, modeSegmented, modeSegmentedDeterministic:
+		if header.version == envelopeVersion {
+			return header, nil
+		}
+
+		fallthrough
 	default:
-		return 0, fmt.Errorf("%w: unsupported mode %d", ErrProcessing, mode)
+		return envelopeHeader{}, fmt.Errorf("%w: unsupported mode %d", ErrProcessing, header.mode)
+	}
+}
+
+// readEnvelopeHeader reads and parses a header from reader.
+// It returns the parsed header together with its raw bytes.
+func readEnvelopeHeader(reader io.Reader) (envelopeHeader, []byte, error) {
+	raw := make([]byte, envelopeHeaderSize)
+	if _, err := io.ReadFull(reader, raw[:envelopeHeaderSizeLegacy]); err != nil {
+		return envelopeHeader{}, nil, fmt.Errorf("reading header: %w", err)
 	}
+
+	if raw[len(envelopeHeaderPrefix)] == envelopeVersionLegacy {
+		raw = raw[:envelopeHeaderSizeLegacy]
+	} else if _, err := io.ReadFull(reader, raw[envelopeHeaderSizeLegacy:]); err != nil {
+		return envelopeHeader{}, nil, fmt.Errorf("reading header: %w", err)
+	}
+
+	header, err := parseEnvelopeHeader(raw)
+	if err != nil {
+		return envelopeHeader{}, nil, err
+	}
+
+	return header, raw, nil
+}
+
+// deriveKeyID derives a short, non-secret identifier for key.
+func deriveKeyID(key []byte) ([]byte, error) {
+	hkdfReader := hkdf.New(sha256.New, key, nil, []byte("gocry/key-id"))
+	keyID := make([]byte, envelopeKeyIDSize)
+
+	if _, err := io.ReadFull(hkdfReader, keyID); err != nil {
+		return nil, fmt.Errorf("deriving key id: %w", err)
+	}
+
+	return keyID, nil
+}
+
+// Fingerprint returns the hex-encoded identifier gocry embeds in envelopes encrypted with key.
+func Fingerprint(key []byte) (string, error) {
+	keyID, err := deriveKeyID(key)
+	if err != nil {
+		return "", err
+	}
+
+	return hex.EncodeToString(keyID), nil
 }
 
 func deriveRandomizedKeys(key []byte) ([]byte, []byte, error) {
@@ -0,0 +1,160 @@
+package encrypt
+
+import (
+	"bytes"
+	"errors"
+	"testing"
+)
+
+func TestEnvelopeHeader(t *testing.T) {
+	t.Parallel()
+
+	key := testKey(t, deterministicKeyLen)
+
+	keyID, err := deriveKeyID(key)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name  string
+		mode  envelopeMode
+		bound bool
+	}{
+		{name: "deterministic", mode: modeDeterministic},
+		{name: "randomized", mode: modeRandomized},
+		{name: "passphrase", mode: modePassphrase},
+		{name: "recipients", mode: modeRecipients},
+		{name: "segmented", mode: modeSegmented},
+		{name: "segmented deterministic", mode: modeSegmentedDeterministic},
+		{name: "deterministic bound", mode: modeDeterministic, bound: true},
+		{name: "segmented bound", mode: modeSegmented, bound: true},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			t.Parallel()
+
+			mode := test.mode
+			if test.bound {
+				mode |= modeFlagBound
+			}
+
+			raw, err := newEnvelopeHeader(mode, key)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			// Data following the header is ignored
+			header, err := parseEnvelopeHeader(append(raw, "payload"...))
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			if header.version != envelopeVersion || header.mode != test.mode || header.bound != test.bound {
+				t.Fatalf("got %+v, want mode %d, bound %t", header, test.mode, test.bound)
+			}
+
+			if !bytes.Equal(header.keyID, keyID) || header.size() != envelopeHeaderSize || len(raw) != header.size() {
+				t.Fatalf("got key ID %x and size %d, want %x and %d", header.keyID, header.size(), keyID, envelopeHeaderSize)
+			}
+		})
+	}
+}
+
+func TestParseEnvelopeHeader(t *testing.T) {
+	t.Parallel()
+
+	// header builds a raw header; version 2 headers get a zero key ID
+	header := func(version byte, mode envelopeMode) []byte {
+		raw := append([]byte(envelopeMagic), version, byte(mode))
+		if version == envelopeVersion {
+			raw = append(raw, make([]byte, envelopeKeyIDSize)...)
+		}
+
+		return raw
+	}
+
+	tests := []struct {
+		name  string
+		data  []byte
+		valid bool
+	}{
+		{name: "version 1 deterministic", data: header(envelopeVersionLegacy, modeDeterministic), valid: true},
+		{name: "version 1 randomized", data: header(envelopeVersionLegacy, modeRandomized), valid: true},
+		{name: "version 2 recipients", data: header(envelopeVersion, modeRecipients), valid: true},
+		{name: "empty", data: nil},
+		{name: "short", data: []byte("GOCR")},
+		{name: "magic", data: append([]byte("gocry"), envelopeVersion, byte(modeDeterministic))},
+		{name: "unknown version", data: header(3, modeDeterministic)},
+		{name: "unknown mode", data: header(envelopeVersion, 0x7f)},
+		{name: "truncated key ID", data: header(envelopeVersion, modeDeterministic)[:envelopeHeaderSize-1]},
+		{name: "version 1 segmented", data: header(envelopeVersionLegacy, modeSegmented)},
+		{name: "version 1 bound", data: header(envelopeVersionLegacy, modeDeterministic|modeFlagBound)},
+		{name: "bound passphrase", data: header(envelopeVersion, modePassphrase|modeFlagBound)},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			t.Parallel()
+
+			parsed, err := parseEnvelopeHeader(test.data)
+			if (err == nil) != test.valid {
+				t.Fatalf("got error %v, want valid %t", err, test.valid)
+			}
+
+			if err != nil && !errors.Is(err, ErrProcessing) {
+				t.Fatalf("got %v, want %v", err, ErrProcessing)
+			}
+
+			if test.valid && parsed.size() != len(test.data) {
+				t.Fatalf("got size %d, want %d", parsed.size(), len(test.data))
+			}
+		})
+	}
+}
+
+func TestLegacyEnvelope(t *testing.T) {
+	t.Parallel()
+
+	data := []byte("secret data")
+	keyring := testKeyring(t, deterministicKeyLen)
+
+	encrypted, err := process(t, Encryptor{Keyring: keyring, Mode: File, Deterministic: true}, Encrypt, data)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// A version 1 envelope has the same payload, behind a header without key ID
+	legacy := append([]byte(envelopeMagic), envelopeVersionLegacy, byte(modeDeterministic))
+	legacy = append(legacy, encrypted[envelopeHeaderSize:]...)
+
+	if !IsEncrypted(legacy) {
+		t.Fatal("version 1 envelope is not recognized")
+	}
+
+	// Without a key ID, every key of the right length is tried
+	candidates, err := NewKeyring([]Key{
+		{Name: "other", Material: testKey(t, deterministicKeyLen)},
+		{Name: "short", Material: testKey(t, randomizedKeyLen)},
+		keyring.Primary(),
+	}, "other")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	decrypted, err := process(t, Encryptor{Keyring: candidates, Mode: File}, Decrypt, legacy)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if !bytes.Equal(decrypted, data) {
+		t.Fatalf("got %q, want %q", decrypted, data)
+	}
+
+	wrong := Encryptor{Keyring: testKeyring(t, randomizedKeyLen), Mode: File}
+
+	if _, err := process(t, wrong, Decrypt, legacy); !errors.Is(err, ErrProcessing) {
+		t.Fatalf("got %v, want %v", err, ErrProcessing)
+	}
+}
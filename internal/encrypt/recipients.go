@@ -0,0 +1,241 @@
+package encrypt
+
+import (
+	"bytes"
+	"crypto/cipher"
+	"crypto/ecdh"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"strings"
+	"sync"
+
+	"golang.org/x/crypto/chacha20poly1305"
+	"golang.org/x/crypto/hkdf"
+)
+
+const (
+	// x25519KeySize is the size of X25519 public and private keys.
+	x25519KeySize = 32
+
+	// stanzaSize is the size of a recipient stanza: [recipient ID | ephemeral public key | wrapped data key].
+	stanzaSize = envelopeKeyIDSize + x25519KeySize + randomizedKeyLen + chacha20poly1305.Overhead
+
+	// maxRecipients is the number of recipients that fit into the stanza count.
+	maxRecipients = 255
+)
+
+// ParseRecipient parses a hex-encoded X25519 public key.
+func ParseRecipient(s string) (*ecdh.PublicKey, error) {
+	raw, err := hex.DecodeString(strings.TrimSpace(s))
+	if err != nil {
+		return nil, fmt.Errorf("invalid recipient: %w", err)
+	}
+
+	recipient, err := ecdh.X25519().NewPublicKey(raw)
+	if err != nil {
+		return nil, fmt.Errorf("invalid recipient: %w", err)
+	}
+
+	return recipient, nil
+}
+
+// ParseIdentities parses hex-encoded X25519 private keys, one per line.
+// Empty lines and lines starting with '#' are ignored.
+func ParseIdentities(data []byte) ([]*ecdh.PrivateKey, error) {
+	var identities []*ecdh.PrivateKey
+
+	for line := range strings.Lines(string(data)) {
+		line = strings.TrimSpace(line)
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+
+		raw, err := hex.DecodeString(line)
+		if err != nil {
+			return nil, fmt.Errorf("invalid identity: %w", err)
+		}
+
+		identity, err := ecdh.X25519().NewPrivateKey(raw)
+		if err != nil {
+			return nil, fmt.Errorf("invalid identity: %w", err)
+		}
+
+		identities = append(identities, identity)
+	}
+
+	if len(identities) == 0 {
+		return nil, fmt.Errorf("%w: no identities found", ErrProcessing)
+	}
+
+	return identities, nil
+}
+
+// Recipients wraps a random data key for one or more X25519 public keys,
+// so that encryption does not require any key able to decrypt.
+// The data key is drawn once per Recipients and shared by all envelopes it produces.
+type Recipients struct {
+	keys []*ecdh.PublicKey
+
+	mutex   sync.Mutex
+	dataKey []byte
+	header  []byte
+}
+
+// NewRecipients creates a recipient set for the given public keys.
+func NewRecipients(keys []*ecdh.PublicKey) (*Recipients, error) {
+	if len(keys) == 0 || len(keys) > maxRecipients {
+		return nil, fmt.Errorf("%w: between 1 and %d recipients required", ErrProcessing, maxRecipients)
+	}
+
+	return &Recipients{keys: keys}, nil
+}
+
+// prefix returns the data key together with the recipients header and stanzas
+// that must precede the inner (randomized) envelope.
+func (r *Recipients) prefix() ([]byte, []byte, error) {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+
+	if r.header != nil {
+		return r.dataKey, r.header, nil
+	}
+
+	dataKey := make([]byte, randomizedKeyLen)
+	if _, err := io.ReadFull(rand.Reader, dataKey); err != nil {
+		return nil, nil, fmt.Errorf("generating data key: %w", err)
+	}
+
+	header, err := newEnvelopeHeader(modeRecipients, dataKey)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	header = append(header, byte(len(r.keys)))
+
+	for _, recipient := range r.keys {
+		stanza, err := wrapDataKey(recipient, dataKey)
+		if err != nil {
+			return nil, nil, err
+		}
+
+		header = append(header, stanza...)
+	}
+
+	r.dataKey, r.header = dataKey, header
+
+	return r.dataKey, r.header, nil
+}
+
+// wrapDataKey encrypts the data key for a single recipient using an ephemeral X25519 key.
+func wrapDataKey(recipient *ecdh.PublicKey, dataKey []byte) ([]byte, error) {
+	ephemeral, err := ecdh.X25519().GenerateKey(rand.Reader)
+	if err != nil {
+		return nil, fmt.Errorf("generating ephemeral key: %w", err)
+	}
+
+	shared, err := ephemeral.ECDH(recipient)
+	if err != nil {
+		return nil, fmt.Errorf("%w: key exchange failed: %w", ErrProcessing, err)
+	}
+
+	aead, err := stanzaAEAD(shared, ephemeral.PublicKey(), recipient)
+	if err != nil {
+		return nil, err
+	}
+
+	recipientID, err := deriveKeyID(recipient.Bytes())
+	if err != nil {
+		return nil, err
+	}
+
+	stanza := make([]byte, 0, stanzaSize)
+	stanza = append(stanza, recipientID...)
+	stanza = append(stanza, ephemeral.PublicKey().Bytes()...)
+
+	// Each wrapping key is used exactly once, so a zero nonce is safe
+	return aead.Seal(stanza, make([]byte, aead.NonceSize()), dataKey, nil), nil
+}
+
+// unwrapDataKey reads the recipient stanzas from reader and decrypts the data key with any of the identities.
+func unwrapDataKey(identities []*ecdh.PrivateKey, reader io.Reader) ([]byte, error) {
+	count := make([]byte, 1)
+	if _, err := io.ReadFull(reader, count); err != nil {
+		return nil, fmt.Errorf("reading recipients: %w", err)
+	}
+
+	stanzas := make([]byte, int(count[0])*stanzaSize)
+	if _, err := io.ReadFull(reader, stanzas); err != nil {
+		return nil, fmt.Errorf("reading recipients: %w", err)
+	}
+
+	if len(identities) == 0 {
+		return nil, fmt.Errorf("%w: data is encrypted for recipients, but no identity was supplied", ErrKeyMismatch)
+	}
+
+	var recipients []string
+
+	for offset := 0; offset < len(stanzas); offset += stanzaSize {
+		stanza := stanzas[offset : offset+stanzaSize]
+		recipientID := stanza[:envelopeKeyIDSize]
+		recipients = append(recipients, fmt.Sprintf("%x", recipientID))
+
+		for _, identity := range identities {
+			identityID, err := deriveKeyID(identity.PublicKey().Bytes())
+			if err != nil {
+				return nil, err
+			}
+
+			if !bytes.Equal(identityID, recipientID) {
+				continue
+			}
+
+			ephemeral, err := ecdh.X25519().NewPublicKey(stanza[envelopeKeyIDSize : envelopeKeyIDSize+x25519KeySize])
+			if err != nil {
+				return nil, fmt.Errorf("%w: invalid ephemeral key: %w", ErrProcessing, err)
+			}
+
+			shared, err := identity.ECDH(ephemeral)
+			if err != nil {
+				return nil, fmt.Errorf("%w: key exchange failed: %w", ErrProcessing, err)
+			}
+
+			aead, err := stanzaAEAD(shared, ephemeral, identity.PublicKey())
+			if err != nil {
+				return nil, err
+			}
+
+			dataKey, err := aead.Open(nil, make([]byte, aead.NonceSize()), stanza[envelopeKeyIDSize+x25519KeySize:], nil)
+			if err != nil {
+				return nil, fmt.Errorf("%w: unwrapping data key failed", ErrProcessing)
+			}
+
+			return dataKey, nil
+		}
+	}
+
+	return nil, fmt.Errorf("%w: encrypted for recipients %s, none of them matches the supplied identities",
+		ErrKeyMismatch, strings.Join(recipients, ", "))
+}
+
+// stanzaAEAD derives the key wrapping AEAD from an X25519 shared secret,
+// bound to the ephemeral public key and the recipient.
+//
+//nolint:ireturn // the AEAD is only available as an interface
+func stanzaAEAD(shared []byte, ephemeral, recipient *ecdh.PublicKey) (cipher.AEAD, error) {
+	salt := append(bytes.Clone(ephemeral.Bytes()), recipient.Bytes()...)
+
+	wrappingKey := make([]byte, chacha20poly1305.KeySize)
+	if _, err := io.ReadFull(hkdf.New(sha256.New, shared, salt, []byte("gocry/x25519")), wrappingKey); err != nil {
+		return nil, fmt.Errorf("deriving wrapping key: %w", err)
+	}
+
+	aead, err := chacha20poly1305.New(wrappingKey)
+	if err != nil {
+		return nil, fmt.Errorf("creating cipher: %w", err)
+	}
+
+	return aead, nil
+}
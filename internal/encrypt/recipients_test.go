@@ -0,0 +1,180 @@
+package encrypt
+
+import (
+	"bytes"
+	"crypto/ecdh"
+	"crypto/rand"
+	"encoding/hex"
+	"errors"
+	"testing"
+)
+
+// testIdentity returns a random X25519 identity.
+func testIdentity(t *testing.T) *ecdh.PrivateKey {
+	t.Helper()
+
+	identity, err := ecdh.X25519().GenerateKey(rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return identity
+}
+
+// testRecipients returns recipients for the public keys of identities.
+func testRecipients(t *testing.T, identities ...*ecdh.PrivateKey) *Recipients {
+	t.Helper()
+
+	keys := make([]*ecdh.PublicKey, 0, len(identities))
+
+	for _, identity := range identities {
+		keys = append(keys, identity.PublicKey())
+	}
+
+	recipients, err := NewRecipients(keys)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return recipients
+}
+
+func TestRecipientsRoundTrip(t *testing.T) {
+	t.Parallel()
+
+	alice, bob := testIdentity(t), testIdentity(t)
+
+	tests := []struct {
+		name  string
+		mode  Mode
+		input []byte
+	}{
+		{name: "file", mode: File, input: []byte("secret data\x00\xff")},
+		{name: "empty file", mode: File, input: []byte{}},
+		{name: "line", mode: Line, input: []byte("user=admin\npassword=hunter2 ### DIRECTIVE: ENCRYPT\n")},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			t.Parallel()
+
+			encrypted, err := process(t, Encryptor{
+				Recipients: testRecipients(t, alice, bob),
+				Mode:       test.mode,
+				Directives: testDirectives,
+				Parallel:   1,
+			}, Encrypt, test.input)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			// Each recipient decrypts on its own
+			for _, identity := range []*ecdh.PrivateKey{alice, bob} {
+				decrypted, err := process(t, Encryptor{
+					Identities: []*ecdh.PrivateKey{identity},
+					Mode:       test.mode,
+					Directives: testDirectives,
+					Parallel:   1,
+				}, Decrypt, encrypted)
+				if err != nil {
+					t.Fatal(err)
+				}
+
+				if !bytes.Equal(decrypted, test.input) {
+					t.Fatalf("got %q, want %q", decrypted, test.input)
+				}
+			}
+		})
+	}
+}
+
+func TestRecipientsRejects(t *testing.T) {
+	t.Parallel()
+
+	alice := testIdentity(t)
+
+	encryptor := Encryptor{Recipients: testRecipients(t, alice), Mode: File, Parallel: 1}
+
+	encrypted, err := process(t, encryptor, Encrypt, []byte("secret data"))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// The stanza follows the header and the recipient count
+	stanza := envelopeHeaderSize + 1
+
+	flip := func(offset int) []byte {
+		tampered := bytes.Clone(encrypted)
+		tampered[offset] ^= 1
+
+		return tampered
+	}
+
+	tests := []struct {
+		name       string
+		identities []*ecdh.PrivateKey
+		input      []byte
+		want       error
+	}{
+		{name: "other identity", identities: []*ecdh.PrivateKey{testIdentity(t)}, input: encrypted, want: ErrKeyMismatch},
+		{name: "no identity", input: encrypted, want: ErrKeyMismatch},
+		{name: "recipient ID", identities: []*ecdh.PrivateKey{alice}, input: flip(stanza), want: ErrKeyMismatch},
+		{
+			name:       "ephemeral key",
+			identities: []*ecdh.PrivateKey{alice},
+			input:      flip(stanza + envelopeKeyIDSize),
+			want:       ErrProcessing,
+		},
+		{name: "wrapped key", identities: []*ecdh.PrivateKey{alice}, input: flip(stanza + stanzaSize - 1), want: ErrProcessing},
+		{name: "payload", identities: []*ecdh.PrivateKey{alice}, input: flip(len(encrypted) - 1), want: ErrProcessing},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			t.Parallel()
+
+			decryptor := Encryptor{Identities: test.identities, Mode: File, Parallel: 1}
+
+			if _, err := process(t, decryptor, Decrypt, test.input); !errors.Is(err, test.want) {
+				t.Fatalf("got %v, want %v", err, test.want)
+			}
+		})
+	}
+
+	t.Run("deterministic", func(t *testing.T) {
+		t.Parallel()
+
+		encryptor := encryptor
+		encryptor.Deterministic = true
+
+		if _, err := process(t, encryptor, Encrypt, []byte("secret data")); !errors.Is(err, ErrProcessing) {
+			t.Fatalf("got %v, want %v", err, ErrProcessing)
+		}
+	})
+}
+
+func TestParseIdentities(t *testing.T) {
+	t.Parallel()
+
+	identity := testIdentity(t)
+	encoded := hex.EncodeToString(identity.Bytes())
+
+	identities, err := ParseIdentities([]byte("# comment\n\n" + encoded + "\n"))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(identities) != 1 || !identities[0].Equal(identity) {
+		t.Fatal("parsed identity does not match")
+	}
+
+	for _, input := range []string{"", "# only a comment\n", "not hex\n", "abcd\n"} {
+		if _, err := ParseIdentities([]byte(input)); err == nil {
+			t.Fatalf("parsing %q succeeded", input)
+		}
+	}
+
+	if _, err := ParseRecipient(hex.EncodeToString(identity.PublicKey().Bytes())); err != nil {
+		t.Fatal(err)
+	}
+}
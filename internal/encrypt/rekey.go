@@ -0,0 +1,154 @@
+package encrypt
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/base64"
+	"fmt"
+	"io"
+)
+
+// Rekey re-encrypts every envelope in the input under the primary key of the given keyring,
+// keeping the deterministic or randomized mode and the path binding of each envelope.
+// The Encryptor's own keyring (or passphrase, or identities) is used to decrypt,
+// and plaintext is only ever held in memory.
+// Envelopes already encrypted with the new key are left untouched.
+// It returns the number of envelopes that were re-encrypted.
+func (e *Encryptor) Rekey(reader io.Reader, writer io.Writer, keyring *Keyring) (int, error) {
+	switch e.Mode {
+	case File:
+		return e.rekeyFile(reader, writer, keyring)
+	case Line:
+		return e.rekeyLines(reader, writer, keyring)
+	default:
+		return 0, fmt.Errorf("%w: rekey not supported in %s mode", ErrProcessing, e.Mode)
+	}
+}
+
+// rekeyFile re-encrypts a whole-file envelope.
+func (e *Encryptor) rekeyFile(reader io.Reader, writer io.Writer, keyring *Keyring) (int, error) {
+	ciphertext, err := io.ReadAll(reader)
+	if err != nil {
+		return 0, fmt.Errorf("reading input: %w", err)
+	}
+
+	out, changed, err := e.rekeyEnvelope(ciphertext, keyring)
+	if err != nil {
+		return 0, err
+	}
+
+	if _, err := writer.Write(out); err != nil {
+		return 0, fmt.Errorf("writing output: %w", err)
+	}
+
+	if changed {
+		return 1, nil
+	}
+
+	return 0, nil
+}
+
+// rekeyLines re-encrypts the envelopes of all decrypt directive lines, including encrypted values.
+func (e *Encryptor) rekeyLines(reader io.Reader, writer io.Writer, keyring *Keyring) (int, error) {
+	count := 0
+
+	scanner := bufio.NewScanner(reader)
+	for scanner.Scan() {
+		line := scanner.Text()
+
+		if _, encoded, ok := e.splitCiphertext(line); ok {
+			ciphertext, err := base64.StdEncoding.DecodeString(encoded)
+			if err != nil {
+				return 0, fmt.Errorf("decoding base64: %w", err)
+			}
+
+			out, changed, err := e.rekeyEnvelope(ciphertext, keyring)
+			if err != nil {
+				return 0, err
+			}
+
+			if changed {
+				line = line[:len(line)-len(encoded)] + base64.StdEncoding.EncodeToString(out)
+				count++
+			}
+		}
+
+		if _, err := fmt.Fprintln(writer, line); err != nil {
+			return 0, fmt.Errorf("%w: writing error: %w", ErrProcessing, err)
+		}
+	}
+
+	if err := scanner.Err(); err != nil {
+		return 0, fmt.Errorf("%w: scanning error: %w", ErrProcessing, err)
+	}
+
+	return count, nil
+}
+
+// rekeyEnvelope decrypts a single envelope and seals it again under the primary key of keyring.
+// It reports whether the envelope changed.
+func (e *Encryptor) rekeyEnvelope(ciphertext []byte, keyring *Keyring) ([]byte, bool, error) {
+	header, err := parseEnvelopeHeader(ciphertext)
+	if err != nil {
+		return nil, false, err
+	}
+
+	key := keyring.Primary().Material
+
+	keyID, err := deriveKeyID(key)
+	if err != nil {
+		return nil, false, err
+	}
+
+	if bytes.Equal(header.keyID, keyID) {
+		return ciphertext, false, nil
+	}
+
+	// Passphrase and recipients envelopes are rekeyed to the mode of the envelope they wrap
+	inner := header
+
+	if header.mode.wraps() {
+		wrapped, err := innerEnvelope(header, ciphertext)
+		if err != nil {
+			return nil, false, err
+		}
+
+		inner, err = parseInnerEnvelopeHeader(wrapped)
+		if err != nil {
+			return nil, false, err
+		}
+	}
+
+	mode := inner.mode
+
+	if mode.deterministic() && len(key) != deterministicKeyLen {
+		return nil, false, fmt.Errorf("%w: deterministic data requires 64-byte new key (128 hex chars)", ErrProcessing)
+	}
+
+	// Randomized whole-file data is upgraded to the segmented layout
+	if mode == modeRandomized && e.Mode == File {
+		mode = modeSegmented
+	}
+
+	if mode.randomized() && len(key) != randomizedKeyLen {
+		return nil, false, fmt.Errorf("%w: randomized data requires 32-byte new key (64 hex chars)", ErrProcessing)
+	}
+
+	plaintext, err := e.decryptEnvelope(ciphertext)
+	if err != nil {
+		return nil, false, err
+	}
+
+	// Envelopes bound to their path stay bound
+	binding, err := e.openBinding(inner)
+	if err != nil {
+		return nil, false, err
+	}
+
+	out, err := sealEnvelope(mode, key, plaintext, binding)
+	if err != nil {
+		return nil, false, err
+	}
+
+	return out, true, nil
+}
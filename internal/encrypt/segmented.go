@@ -0,0 +1,367 @@
+package encrypt
+
+import (
+	"bufio"
+	"bytes"
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"sync"
+
+	"github.com/tink-crypto/tink-go/v2/tink"
+	"golang.org/x/crypto/hkdf"
+)
+
+// errSegmentsFailed stops reading once a segment failed, the failure itself is reported separately.
+var errSegmentsFailed = errors.New("segment failed")
+
+const (
+	// segmentSize is the amount of plaintext sealed per segment.
+	segmentSize = 64 << 10
+
+	// segmentMaxSize bounds the segment size accepted during decryption.
+	segmentMaxSize = 16 << 20
+
+	// segmentSaltSize is the size of the per-file salt the randomized segment key is derived with.
+	segmentSaltSize = 16
+
+	// segmentNonceSize is the size of the per-segment nonce: [zero padding | index (uint64) | final flag].
+	segmentNonceSize = 12
+
+	// segmentTagSize is the authentication overhead of a segment, both for AES-GCM and AES-SIV.
+	segmentTagSize = 16
+)
+
+// segmentCipher seals and opens the segments of a segmented envelope (modeSegmented or modeSegmentedDeterministic).
+// Each segment is sealed under a nonce made of the segment index and a flag marking the final segment,
+// and the envelope preamble (with the path binding, if any) is authenticated with every segment,
+// so segments cannot be reordered, dropped, truncated or moved between files.
+//
+// Randomized envelopes use AES-256-GCM under a key derived from a per-file salt.
+// Deterministic envelopes use AES-SIV with the nonce as associated data,
+// so the same plaintext and key always give the same ciphertext.
+type segmentCipher struct {
+	aead  cipher.AEAD
+	daead tink.DeterministicAEAD
+	ad    []byte
+	size  int
+}
+
+// newSegmentCipher creates the segment cipher for mode from key, the preamble and the binding,
+// deriving the per-file key from the salt at the end of a randomized preamble.
+func newSegmentCipher(mode envelopeMode, key, preamble, binding []byte, size int) (*segmentCipher, error) {
+	ad := append(bytes.Clone(preamble), binding...)
+
+	if mode == modeSegmentedDeterministic {
+		daead, err := newDAEAD(key)
+		if err != nil {
+			return nil, err
+		}
+
+		return &segmentCipher{daead: daead, ad: ad, size: size}, nil
+	}
+
+	salt := preamble[len(preamble)-segmentSaltSize:]
+
+	segmentKey := make([]byte, randomizedKeyLen)
+	if _, err := io.ReadFull(hkdf.New(sha256.New, key, salt, []byte("gocry/segmented")), segmentKey); err != nil {
+		return nil, fmt.Errorf("deriving segment key: %w", err)
+	}
+
+	block, err := aes.NewCipher(segmentKey)
+	if err != nil {
+		return nil, fmt.Errorf("creating cipher: %w", err)
+	}
+
+	aead, err := cipher.NewGCM(block)
+	if err != nil {
+		return nil, fmt.Errorf("creating cipher: %w", err)
+	}
+
+	return &segmentCipher{aead: aead, ad: ad, size: size}, nil
+}
+
+// nonce builds the nonce for the segment at index: [zero padding | index (uint64) | final flag].
+func (c *segmentCipher) nonce(index uint64, final bool) []byte {
+	nonce := make([]byte, segmentNonceSize)
+
+	binary.BigEndian.PutUint64(nonce[segmentNonceSize-9:], index)
+
+	if final {
+		nonce[segmentNonceSize-1] = 1
+	}
+
+	return nonce
+}
+
+// seal encrypts and authenticates a single segment.
+func (c *segmentCipher) seal(index uint64, final bool, plaintext []byte) ([]byte, error) {
+	nonce := c.nonce(index, final)
+
+	if c.daead == nil {
+		return c.aead.Seal(nil, nonce, plaintext, c.ad), nil
+	}
+
+	segment, err := c.daead.EncryptDeterministically(plaintext, append(bytes.Clone(c.ad), nonce...))
+	if err != nil {
+		return nil, fmt.Errorf("%w: encryption failed in segment %d: %w", ErrProcessing, index, err)
+	}
+
+	return segment, nil
+}
+
+// open verifies and decrypts a single segment.
+func (c *segmentCipher) open(index uint64, final bool, segment []byte) ([]byte, error) {
+	var (
+		plaintext []byte
+		err       error
+	)
+
+	nonce := c.nonce(index, final)
+
+	if c.daead == nil {
+		plaintext, err = c.aead.Open(nil, nonce, segment, c.ad)
+	} else {
+		plaintext, err = c.daead.DecryptDeterministically(segment, append(bytes.Clone(c.ad), nonce...))
+	}
+
+	switch {
+	case err != nil && final:
+		return nil, fmt.Errorf("%w: authentication failed in segment %d, or data truncated", ErrProcessing, index)
+	case err != nil:
+		return nil, fmt.Errorf("%w: authentication failed in segment %d", ErrProcessing, index)
+	}
+
+	return plaintext, nil
+}
+
+// encryptSegmented encrypts data from reader to writer in authenticated segments,
+// using modeSegmented or modeSegmentedDeterministic. Segments are sealed by up to parallel workers,
+// and a non-nil binding is authenticated with each of them.
+// The output layout is: [header | segment size | salt (randomized only) | segment 0 | ... | final segment],
+// where each segment holds up to segmentSize bytes of ciphertext and its tag.
+func encryptSegmented(mode envelopeMode, key, binding []byte, reader io.Reader, writer io.Writer, parallel int) error {
+	header, err := newEnvelopeHeader(mode.bind(binding), key)
+	if err != nil {
+		return err
+	}
+
+	preamble := binary.BigEndian.AppendUint32(header, segmentSize)
+
+	if mode == modeSegmented {
+		salt := make([]byte, segmentSaltSize)
+		if _, err := io.ReadFull(rand.Reader, salt); err != nil {
+			return fmt.Errorf("generating salt: %w", err)
+		}
+
+		preamble = append(preamble, salt...)
+	}
+
+	segments, err := newSegmentCipher(mode, key, preamble, binding, segmentSize)
+	if err != nil {
+		return err
+	}
+
+	if _, err := writer.Write(preamble); err != nil {
+		return fmt.Errorf("writing header: %w", err)
+	}
+
+	return transformSegments(reader, writer, segmentSize, parallel, segments.seal)
+}
+
+// decryptSegmented verifies and decrypts data produced by encryptSegmented, with the same binding.
+// The header has already been consumed from reader. Segments are opened by up to parallel workers,
+// each one is authenticated before its plaintext is written, and a missing final segment is reported as truncation.
+func decryptSegmented(key, binding []byte, reader io.Reader, writer io.Writer, header []byte, parallel int) error {
+	segments, err := readSegmentCipher(key, binding, reader, header)
+	if err != nil {
+		return err
+	}
+
+	return transformSegments(reader, writer, segments.size+segmentTagSize, parallel, segments.open)
+}
+
+// readSegmentCipher reads the segment parameters following header from reader.
+func readSegmentCipher(key, binding []byte, reader io.Reader, header []byte) (*segmentCipher, error) {
+	parsed, err := parseEnvelopeHeader(header)
+	if err != nil {
+		return nil, err
+	}
+
+	params := make([]byte, 4, 4+segmentSaltSize)
+
+	if parsed.mode == modeSegmented {
+		params = params[:4+segmentSaltSize]
+	}
+
+	if _, err := io.ReadFull(reader, params); err != nil {
+		return nil, fmt.Errorf("reading segment parameters: %w", err)
+	}
+
+	size := int(binary.BigEndian.Uint32(params))
+	if size == 0 || size > segmentMaxSize {
+		return nil, fmt.Errorf("%w: invalid segment size %d", ErrProcessing, size)
+	}
+
+	preamble := append(bytes.Clone(header), params...)
+
+	return newSegmentCipher(parsed.mode, key, preamble, binding, size)
+}
+
+// segmentResult holds the outcome of transforming a single segment.
+type segmentResult struct {
+	// out is the transformed segment
+	out []byte
+
+	// err is any error encountered
+	err error
+}
+
+// segmentJob is a segment queued for transformation, together with the channel receiving its result.
+type segmentJob struct {
+	index  uint64
+	final  bool
+	block  []byte
+	result chan segmentResult
+}
+
+// transformSegments reads reader in blocks of size bytes, transforms each block and writes the results to writer.
+// With more than one worker, blocks are transformed concurrently and still written in order.
+// At most parallel blocks are held in memory at once beyond the one being read,
+// and reading stops at the first failed segment, whose error is returned.
+//
+//nolint:gocognit // function complexity is acceptable
+func transformSegments(
+	reader io.Reader,
+	writer io.Writer,
+	size, parallel int,
+	transform func(index uint64, final bool, block []byte) ([]byte, error),
+) error {
+	write := func(result segmentResult) error {
+		if result.err != nil {
+			return result.err
+		}
+
+		if _, err := writer.Write(result.out); err != nil {
+			return fmt.Errorf("writing data: %w", err)
+		}
+
+		return nil
+	}
+
+	if parallel <= 1 {
+		return readSegments(reader, size, func(index uint64, final bool, block []byte) error {
+			out, err := transform(index, final, block)
+
+			return write(segmentResult{out: out, err: err})
+		})
+	}
+
+	work := make(chan segmentJob)
+	pending := make(chan chan segmentResult, parallel)
+	failed := make(chan struct{})
+	written := make(chan error, 1)
+
+	var waitGroup sync.WaitGroup
+
+	for range parallel {
+		waitGroup.Add(1)
+
+		go func() {
+			defer waitGroup.Done()
+
+			for job := range work {
+				out, err := transform(job.index, job.final, job.block)
+				job.result <- segmentResult{out: out, err: err}
+			}
+		}()
+	}
+
+	// Results are written in the order their segments were read
+	go func() {
+		var err error
+
+		for result := range pending {
+			if err != nil {
+				<-result
+
+				continue
+			}
+
+			if err = write(<-result); err != nil {
+				close(failed)
+			}
+		}
+
+		written <- err
+	}()
+
+	err := readSegments(reader, size, func(index uint64, final bool, block []byte) error {
+		job := segmentJob{index: index, final: final, block: bytes.Clone(block), result: make(chan segmentResult, 1)}
+
+		select {
+		case pending <- job.result:
+		case <-failed:
+			return errSegmentsFailed
+		}
+
+		work <- job
+
+		return nil
+	})
+
+	close(work)
+	close(pending)
+	waitGroup.Wait()
+
+	if writeErr := <-written; writeErr != nil {
+		return writeErr
+	}
+
+	return err
+}
+
+// readSegments splits reader into consecutive blocks of size bytes and calls handle for each,
+// flagging the last one as final. Empty input yields a single, empty final block;
+// input ending exactly on a block boundary has no trailing empty block.
+func readSegments(reader io.Reader, size int, handle func(index uint64, final bool, block []byte) error) error {
+	buffered := bufio.NewReaderSize(reader, size)
+	block := make([]byte, size)
+
+	for index := uint64(0); ; index++ {
+		n, err := io.ReadFull(buffered, block)
+
+		var final bool
+
+		switch {
+		case errors.Is(err, io.EOF) && index > 0:
+			return fmt.Errorf("%w: data truncated after segment %d", ErrProcessing, index-1)
+		case errors.Is(err, io.EOF), errors.Is(err, io.ErrUnexpectedEOF):
+			final = true
+		case err != nil:
+			return fmt.Errorf("reading data: %w", err)
+		default:
+			_, err := buffered.Peek(1)
+
+			switch {
+			case errors.Is(err, io.EOF):
+				final = true
+			case err != nil:
+				return fmt.Errorf("reading data: %w", err)
+			}
+		}
+
+		if err := handle(index, final, block[:n]); err != nil {
+			return err
+		}
+
+		if final {
+			return nil
+		}
+	}
+}
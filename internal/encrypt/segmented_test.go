@@ -0,0 +1,371 @@
+package encrypt
+
+import (
+	"bytes"
+	"crypto/rand"
+	"errors"
+	"fmt"
+	"io"
+	"runtime"
+	"slices"
+	"testing"
+)
+
+const benchmarkSize = 64 << 20
+
+// benchmarkInput returns random plaintext and a key of the given length.
+func benchmarkInput(b *testing.B, keyLen int) ([]byte, []byte) {
+	b.Helper()
+
+	data := make([]byte, benchmarkSize)
+	key := make([]byte, keyLen)
+
+	for _, buf := range [][]byte{data, key} {
+		if _, err := rand.Read(buf); err != nil {
+			b.Fatal(err)
+		}
+	}
+
+	return data, key
+}
+
+// benchmarkWorkers lists the worker counts to compare.
+func benchmarkWorkers() []int {
+	workers := []int{1, 4, runtime.NumCPU()}
+
+	slices.Sort(workers)
+
+	return slices.Compact(workers)
+}
+
+// benchmarkModes maps the segmented modes to their names and key lengths.
+//
+//nolint:gochecknoglobals // these globals are acceptable
+var benchmarkModes = []struct {
+	name   string
+	mode   envelopeMode
+	keyLen int
+}{
+	{name: "randomized", mode: modeSegmented, keyLen: randomizedKeyLen},
+	{name: "deterministic", mode: modeSegmentedDeterministic, keyLen: deterministicKeyLen},
+}
+
+func BenchmarkEncryptStream(b *testing.B) {
+	data, key := benchmarkInput(b, randomizedKeyLen)
+
+	b.SetBytes(int64(len(data)))
+
+	for b.Loop() {
+		if err := encryptStream(key, bytes.NewReader(data), io.Discard); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
+
+func BenchmarkDecryptStream(b *testing.B) {
+	data, key := benchmarkInput(b, randomizedKeyLen)
+
+	var encrypted bytes.Buffer
+	if err := encryptStream(key, bytes.NewReader(data), &encrypted); err != nil {
+		b.Fatal(err)
+	}
+
+	b.SetBytes(int64(len(data)))
+
+	for b.Loop() {
+		reader := bytes.NewReader(encrypted.Bytes())
+
+		_, raw, err := readEnvelopeHeader(reader)
+		if err != nil {
+			b.Fatal(err)
+		}
+
+		if err := decryptStream(key, reader, io.Discard, raw); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
+
+func BenchmarkEncryptSegmented(b *testing.B) {
+	for _, benchmark := range benchmarkModes {
+		mode := benchmark.mode
+		data, key := benchmarkInput(b, benchmark.keyLen)
+
+		for _, parallel := range benchmarkWorkers() {
+			b.Run(fmt.Sprintf("%s/parallel=%d", benchmark.name, parallel), func(b *testing.B) {
+				b.SetBytes(int64(len(data)))
+
+				for b.Loop() {
+					if err := encryptSegmented(mode, key, nil, bytes.NewReader(data), io.Discard, parallel); err != nil {
+						b.Fatal(err)
+					}
+				}
+			})
+		}
+	}
+}
+
+func BenchmarkDecryptSegmented(b *testing.B) {
+	for _, benchmark := range benchmarkModes {
+		mode := benchmark.mode
+		data, key := benchmarkInput(b, benchmark.keyLen)
+
+		var encrypted bytes.Buffer
+		if err := encryptSegmented(mode, key, nil, bytes.NewReader(data), &encrypted, 1); err != nil {
+			b.Fatal(err)
+		}
+
+		for _, parallel := range benchmarkWorkers() {
+			b.Run(fmt.Sprintf("%s/parallel=%d", benchmark.name, parallel), func(b *testing.B) {
+				b.SetBytes(int64(len(data)))
+
+				for b.Loop() {
+					reader := bytes.NewReader(encrypted.Bytes())
+
+					_, raw, err := readEnvelopeHeader(reader)
+					if err != nil {
+						b.Fatal(err)
+					}
+
+					if err := decryptSegmented(key, nil, reader, io.Discard, raw, parallel); err != nil {
+						b.Fatal(err)
+					}
+				}
+			})
+		}
+	}
+}
+
+// segmentedTests lists the segmented modes with the encryptor settings producing them.
+//
+//nolint:gochecknoglobals // these globals are acceptable
+var segmentedTests = []struct {
+	name          string
+	mode          envelopeMode
+	keyLen        int
+	deterministic bool
+	preamble      int
+}{
+	{
+		name:     "randomized",
+		mode:     modeSegmented,
+		keyLen:   randomizedKeyLen,
+		preamble: envelopeHeaderSize + 4 + segmentSaltSize,
+	},
+	{
+		name:          "deterministic",
+		mode:          modeSegmentedDeterministic,
+		keyLen:        deterministicKeyLen,
+		deterministic: true,
+		preamble:      envelopeHeaderSize + 4,
+	},
+}
+
+// segmentedEncryptor returns a whole-file encryptor producing the envelope of test.
+func segmentedEncryptor(t *testing.T, keyLen int, deterministic bool, parallel int) Encryptor {
+	t.Helper()
+
+	return Encryptor{
+		Keyring:       testKeyring(t, keyLen),
+		Mode:          File,
+		Parallel:      parallel,
+		Deterministic: deterministic,
+		Chunked:       deterministic,
+	}
+}
+
+// randomData returns size random bytes.
+func randomData(t *testing.T, size int) []byte {
+	t.Helper()
+
+	return testKey(t, size)
+}
+
+func TestSegmentedRoundTrip(t *testing.T) {
+	t.Parallel()
+
+	sizes := []int{0, 1, segmentSize - 1, segmentSize, segmentSize + 1, 3*segmentSize + 17}
+
+	for _, test := range segmentedTests {
+		for _, size := range sizes {
+			t.Run(fmt.Sprintf("%s/%d", test.name, size), func(t *testing.T) {
+				t.Parallel()
+
+				data := randomData(t, size)
+				encrypted := roundTrip(t, segmentedEncryptor(t, test.keyLen, test.deterministic, 1), data, data)
+
+				header, err := parseEnvelopeHeader(encrypted)
+				if err != nil {
+					t.Fatal(err)
+				}
+
+				if header.version != envelopeVersion || header.mode != test.mode || header.bound {
+					t.Fatalf("got version %d, mode %d, bound %t", header.version, header.mode, header.bound)
+				}
+
+				segments := max(1, (size+segmentSize-1)/segmentSize)
+				if want := test.preamble + size + segments*segmentTagSize; len(encrypted) != want {
+					t.Fatalf("got %d bytes, want %d", len(encrypted), want)
+				}
+			})
+		}
+	}
+}
+
+func TestSegmentedTampered(t *testing.T) {
+	t.Parallel()
+
+	for _, test := range segmentedTests {
+		t.Run(test.name, func(t *testing.T) {
+			t.Parallel()
+
+			encryptor := segmentedEncryptor(t, test.keyLen, test.deterministic, 1)
+
+			encrypted, err := process(t, encryptor, Encrypt, randomData(t, 3*segmentSize+100))
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			sealed := segmentSize + segmentTagSize
+			first, second := test.preamble, test.preamble+sealed
+
+			flip := func(offset int, mask byte) []byte {
+				tampered := bytes.Clone(encrypted)
+				tampered[offset] ^= mask
+
+				return tampered
+			}
+
+			swapped := bytes.Clone(encrypted)
+			copy(swapped[first:], encrypted[second:second+sealed])
+			copy(swapped[second:], encrypted[first:first+sealed])
+
+			tests := []struct {
+				name  string
+				input []byte
+				want  error
+			}{
+				{name: "reordered segments", input: swapped, want: ErrProcessing},
+				{name: "truncated at a segment boundary", input: encrypted[:second+sealed], want: ErrProcessing},
+				{name: "final segment dropped", input: encrypted[:second], want: ErrProcessing},
+				{name: "truncated in a segment", input: encrypted[:len(encrypted)-1], want: ErrProcessing},
+				{name: "magic", input: flip(0, 1), want: ErrProcessing},
+				{name: "version", input: flip(len(envelopeMagic), 1), want: ErrProcessing},
+				{name: "mode", input: flip(len(envelopeMagic)+1, 1)},
+				{name: "bound flag", input: flip(len(envelopeMagic)+1, byte(modeFlagBound))},
+				{name: "key ID", input: flip(envelopeHeaderSizeLegacy, 1), want: ErrKeyMismatch},
+				{name: "segment size", input: flip(envelopeHeaderSize+3, 1)},
+				{name: "preamble end", input: flip(test.preamble-1, 1), want: ErrProcessing},
+				{name: "segment", input: flip(second+10, 1), want: ErrProcessing},
+				{name: "tag", input: flip(len(encrypted)-1, 1), want: ErrProcessing},
+			}
+
+			for _, tamper := range tests {
+				t.Run(tamper.name, func(t *testing.T) {
+					t.Parallel()
+
+					_, err := process(t, encryptor, Decrypt, tamper.input)
+
+					switch {
+					case err == nil:
+						t.Fatal("decrypting tampered data succeeded")
+					case tamper.want != nil && !errors.Is(err, tamper.want):
+						t.Fatalf("got %v, want %v", err, tamper.want)
+					}
+				})
+			}
+		})
+	}
+}
+
+func TestSegmentedDeterministic(t *testing.T) {
+	t.Parallel()
+
+	encryptor := segmentedEncryptor(t, deterministicKeyLen, true, 1)
+	data := randomData(t, 2*segmentSize+5)
+
+	first, err := process(t, encryptor, Encrypt, data)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	second, err := process(t, encryptor, Encrypt, data)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if !bytes.Equal(first, second) {
+		t.Fatal("chunked deterministic encryption is not stable")
+	}
+
+	// Identical segments at different positions encrypt differently
+	repeated := bytes.Repeat(data[:segmentSize], 2)
+
+	encrypted, err := process(t, encryptor, Encrypt, repeated)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	sealed, preamble := segmentSize+segmentTagSize, envelopeHeaderSize+4
+	if bytes.Equal(encrypted[preamble:preamble+sealed], encrypted[preamble+sealed:preamble+2*sealed]) {
+		t.Fatal("identical segments give identical ciphertext")
+	}
+}
+
+func TestSegmentedParallel(t *testing.T) {
+	t.Parallel()
+
+	data := randomData(t, 5*segmentSize+3)
+
+	for _, test := range segmentedTests {
+		t.Run(test.name, func(t *testing.T) {
+			t.Parallel()
+
+			sequential := segmentedEncryptor(t, test.keyLen, test.deterministic, 1)
+
+			parallel := sequential
+			parallel.Parallel = 4
+
+			fromSequential, err := process(t, sequential, Encrypt, data)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			fromParallel, err := process(t, parallel, Encrypt, data)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			// Randomized envelopes differ by their salt, deterministic ones must be identical
+			if test.deterministic && !bytes.Equal(fromSequential, fromParallel) {
+				t.Fatal("parallel encryption differs from sequential encryption")
+			}
+
+			for _, encrypted := range [][]byte{fromSequential, fromParallel} {
+				for _, decryptor := range []Encryptor{sequential, parallel} {
+					decrypted, err := process(t, decryptor, Decrypt, encrypted)
+					if err != nil {
+						t.Fatal(err)
+					}
+
+					if !bytes.Equal(decrypted, data) {
+						t.Fatalf("decrypting with %d workers gave different data", decryptor.Parallel)
+					}
+				}
+			}
+
+			// A failed segment stops parallel decryption before anything after it is written
+			tampered := bytes.Clone(fromParallel)
+			tampered[test.preamble+2*(segmentSize+segmentTagSize)+1] ^= 1
+
+			decrypted, err := process(t, parallel, Decrypt, tampered)
+			if !errors.Is(err, ErrProcessing) {
+				t.Fatalf("got %v, want %v", err, ErrProcessing)
+			}
+
+			if len(decrypted) > 2*segmentSize {
+				t.Fatalf("wrote %d bytes past the failed segment", len(decrypted)-2*segmentSize)
+			}
+		})
+	}
+}
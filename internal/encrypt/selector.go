@@ -0,0 +1,109 @@
+package encrypt
+
+import (
+	"fmt"
+	"regexp"
+	"slices"
+	"strings"
+)
+
+// Selector decides which values of a structured document are encrypted.
+// Values are addressed by their path, the keys and list indices leading to them.
+// A nil or empty Selector selects every value.
+type Selector struct {
+	// paths are the split path patterns
+	paths [][]string
+
+	// keys matches the key a value is stored under
+	keys *regexp.Regexp
+}
+
+// NewSelector creates a selector from path patterns and a key regular expression, either of which may be empty.
+//
+// Path patterns separate keys with dots, as in `spec.template.env`. A `*` segment matches any single key
+// or index, and a `**` segment matches any number of them. JSONPath-like patterns such as `$.data[*].password`
+// are accepted as well.
+func NewSelector(paths []string, keyPattern string) (*Selector, error) {
+	selector := &Selector{}
+
+	for _, path := range paths {
+		segments := splitSelectorPath(path)
+		if len(segments) == 0 {
+			return nil, fmt.Errorf("%w: empty selector %q", ErrProcessing, path)
+		}
+
+		selector.paths = append(selector.paths, segments)
+	}
+
+	if keyPattern != "" {
+		keys, err := regexp.Compile(keyPattern)
+		if err != nil {
+			return nil, fmt.Errorf("%w: invalid key pattern %q: %w", ErrProcessing, keyPattern, err)
+		}
+
+		selector.keys = keys
+	}
+
+	return selector, nil
+}
+
+// splitSelectorPath splits a path pattern into its segments, normalizing the JSONPath-like notation.
+func splitSelectorPath(path string) []string {
+	path = strings.TrimPrefix(strings.TrimSpace(path), "$")
+	path = strings.NewReplacer("[", ".", "]", "").Replace(path)
+
+	var segments []string
+
+	for segment := range strings.SplitSeq(path, ".") {
+		if segment != "" {
+			segments = append(segments, strings.Trim(segment, `'"`))
+		}
+	}
+
+	return segments
+}
+
+// Matches reports whether the value at path is selected.
+// A value is selected when a path pattern matches its path or one of its ancestors,
+// or when the key pattern matches any key along its path, so that selecting a mapping selects all values below it.
+func (s *Selector) Matches(path []string) bool {
+	if s == nil || (len(s.paths) == 0 && s.keys == nil) {
+		return true
+	}
+
+	if s.keys != nil && slices.ContainsFunc(path, s.keys.MatchString) {
+		return true
+	}
+
+	for _, pattern := range s.paths {
+		for end := 1; end <= len(path); end++ {
+			if matchSegments(pattern, path[:end]) {
+				return true
+			}
+		}
+	}
+
+	return false
+}
+
+// matchSegments matches a path against a split pattern, where `*` matches one segment and `**` any number.
+func matchSegments(pattern, path []string) bool {
+	switch {
+	case len(pattern) == 0:
+		return len(path) == 0
+	case pattern[0] == "**":
+		for skip := 0; skip <= len(path); skip++ {
+			if matchSegments(pattern[1:], path[skip:]) {
+				return true
+			}
+		}
+
+		return false
+	case len(path) == 0:
+		return false
+	case pattern[0] == "*" || pattern[0] == path[0]:
+		return matchSegments(pattern[1:], path[1:])
+	default:
+		return false
+	}
+}
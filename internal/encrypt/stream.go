@@ -14,8 +14,8 @@ const streamBufferSize = 4096
 
 // encryptStream encrypts data from reader to writer using AES-CTR mode protected by an HMAC tag.
 // The output layout is: [header | IV | ciphertext | tag].
-func (e *Encryptor) encryptStream(reader io.Reader, writer io.Writer) error {
-	encKey, macKey, err := deriveRandomizedKeys(e.Key)
+func encryptStream(key []byte, reader io.Reader, writer io.Writer) error {
+	encKey, macKey, err := deriveRandomizedKeys(key)
 	if err != nil {
 		return err
 	}
@@ -25,7 +25,11 @@ func (e *Encryptor) encryptStream(reader io.Reader, writer io.Writer) error {
 		return fmt.Errorf("creating cipher: %w", err)
 	}
 
-	header := newEnvelopeHeader(modeRandomized)
+	header, err := newEnvelopeHeader(modeRandomized, key)
+	if err != nil {
+		return err
+	}
+
 	if _, err := writer.Write(header); err != nil {
 		return fmt.Errorf("writing header: %w", err)
 	}
@@ -80,8 +84,8 @@ func (e *Encryptor) encryptStream(reader io.Reader, writer io.Writer) error {
 //
 
 //nolint:gocognit	// function complexity is acceptable
-func (e *Encryptor) decryptStream(reader io.Reader, writer io.Writer, header []byte) error {
-	encKey, macKey, err := deriveRandomizedKeys(e.Key)
+func decryptStream(key []byte, reader io.Reader, writer io.Writer, header []byte) error {
+	encKey, macKey, err := deriveRandomizedKeys(key)
 	if err != nil {
 		return err
 	}
@@ -156,21 +160,22 @@ func (e *Encryptor) decryptStream(reader io.Reader, writer io.Writer, header []b
 }
 
 // encryptDeterministic encrypts the entire data buffer deterministically using AES-SIV.
-func (e *Encryptor) encryptDeterministic(data []byte) ([]byte, error) {
-	daead, err := newDAEAD(e.Key)
+// A non-nil binding is authenticated as associated data.
+func encryptDeterministic(key, data, binding []byte) ([]byte, error) {
+	daead, err := newDAEAD(key)
 	if err != nil {
 		return nil, err
 	}
 
-	return daead.EncryptDeterministically(data, nil) //nolint:wrapcheck // error does not need wrapping
+	return daead.EncryptDeterministically(data, binding) //nolint:wrapcheck // error does not need wrapping
 }
 
-// decryptDeterministic decrypts data previously encrypted with AES-SIV.
-func (e *Encryptor) decryptDeterministic(data []byte) ([]byte, error) {
-	daead, err := newDAEAD(e.Key)
+// decryptDeterministic decrypts data previously encrypted with AES-SIV, with the same binding.
+func decryptDeterministic(key, data, binding []byte) ([]byte, error) {
+	daead, err := newDAEAD(key)
 	if err != nil {
 		return nil, err
 	}
 
-	return daead.DecryptDeterministically(data, nil) //nolint:wrapcheck // error does not need wrapping
+	return daead.DecryptDeterministically(data, binding) //nolint:wrapcheck // error does not need wrapping
 }
@@ -0,0 +1,360 @@
+package encrypt
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"io"
+	"slices"
+	"strings"
+)
+
+// processTOML encrypts or decrypts the selected values of a TOML document.
+// A value is addressed by its table and (possibly dotted) key, as in `database.password`.
+// Tables in an array of tables share the path of their header.
+// Each value is encrypted as written into a string, so that decryption restores its literal, and therefore its type,
+// byte for byte. Comments, blank lines and the layout of each line are kept.
+// Returns true if any value was encrypted or decrypted.
+//
+//nolint:gocognit // function complexity is acceptable
+func (e *Encryptor) processTOML(reader io.Reader, writer io.Writer) (bool, error) {
+	var (
+		table        []string
+		anyProcessed bool
+		number       int
+	)
+
+	buffered := bufio.NewReader(reader)
+
+	for {
+		text, err := buffered.ReadString('\n')
+		if err != nil && !errors.Is(err, io.EOF) {
+			return false, fmt.Errorf("reading input: %w", err)
+		}
+
+		if text == "" {
+			return anyProcessed, nil
+		}
+
+		number++
+
+		trimmed := strings.TrimSpace(text)
+
+		switch {
+		case trimmed == "", strings.HasPrefix(trimmed, "#"):
+		case strings.HasPrefix(trimmed, "["):
+			header := strings.Trim(trimmed, "[]")
+			if end := closingTOMLBracket(trimmed); end > 0 {
+				header = strings.Trim(trimmed[:end], "[]")
+			}
+
+			table = splitTOMLKey(header)
+		default:
+			eq := tomlKeyEnd(text)
+			if eq < 0 {
+				return false, fmt.Errorf("%w: parsing toml: line %d: expected key = value", ErrProcessing, number)
+			}
+
+			start := eq + 1 + len(text[eq+1:]) - len(strings.TrimLeft(text[eq+1:], " \t"))
+			rest := text[start:]
+			first := number
+
+			end := tomlValueEnd(rest)
+			for end < 0 {
+				next, err := buffered.ReadString('\n')
+				if err != nil && !errors.Is(err, io.EOF) {
+					return false, fmt.Errorf("reading input: %w", err)
+				}
+
+				if next == "" {
+					return false, fmt.Errorf("%w: parsing toml: line %d: unterminated value", ErrProcessing, first)
+				}
+
+				number++
+				rest += next
+				end = tomlValueEnd(rest)
+			}
+
+			path := slices.Concat(table, splitTOMLKey(text[:eq]))
+
+			replacement, processed, err := e.processTOMLMember(rest[:end], path)
+			if err != nil {
+				return false, err
+			}
+
+			text = text[:start] + replacement + rest[end:]
+			anyProcessed = anyProcessed || processed
+		}
+
+		if _, err := io.WriteString(writer, text); err != nil {
+			return false, fmt.Errorf("%w: writing error: %w", ErrProcessing, err)
+		}
+	}
+}
+
+// processTOMLMember processes the value of a key. Inline tables that are not selected as a whole are
+// processed member by member, so that selectors can address their keys; decryption always looks inside them.
+func (e *Encryptor) processTOMLMember(value string, path []string) (string, bool, error) {
+	if strings.HasPrefix(value, "{") && (e.Operation == Decrypt || !e.Selector.Matches(path)) {
+		return e.processTOMLInline(value, path)
+	}
+
+	return e.processTOMLValue(value, path)
+}
+
+// processTOMLInline processes the members of an inline table, as in `a = { b = "x", c = 1 }`,
+// keeping the layout between them.
+func (e *Encryptor) processTOMLInline(table string, path []string) (string, bool, error) {
+	var (
+		out          strings.Builder
+		anyProcessed bool
+	)
+
+	out.WriteByte('{')
+
+	rest := table[1:]
+
+	for {
+		eq := tomlKeyEnd(rest)
+		if eq < 0 {
+			out.WriteString(rest)
+
+			return out.String(), anyProcessed, nil
+		}
+
+		start := eq + 1 + len(rest[eq+1:]) - len(strings.TrimLeft(rest[eq+1:], " \t"))
+
+		end := tomlMemberEnd(rest[start:])
+		if end < 0 {
+			return "", false, fmt.Errorf("%w: parsing toml: %q: unterminated inline table", ErrProcessing,
+				strings.Join(path, "."))
+		}
+
+		replacement, processed, err := e.processTOMLMember(rest[start:start+end],
+			slices.Concat(path, splitTOMLKey(rest[:eq])))
+		if err != nil {
+			return "", false, err
+		}
+
+		out.WriteString(rest[:start])
+		out.WriteString(replacement)
+
+		anyProcessed = anyProcessed || processed
+
+		// Copy the separator following the member, which is either a comma or the closing brace
+		rest = rest[start+end:]
+		sep := strings.IndexAny(rest, ",}") + 1
+
+		out.WriteString(rest[:sep])
+
+		if rest[sep-1] == '}' {
+			out.WriteString(rest[sep:])
+
+			return out.String(), anyProcessed, nil
+		}
+
+		rest = rest[sep:]
+	}
+}
+
+// processTOMLValue encrypts a selected literal into a string, or decrypts a string holding an encrypted literal.
+func (e *Encryptor) processTOMLValue(value string, path []string) (string, bool, error) {
+	if e.Operation == Decrypt {
+		if len(value) < 2 || value[0] != '"' || value[len(value)-1] != '"' {
+			return value, false, nil
+		}
+
+		decrypted, processed, err := e.processRawValue(value[1:len(value)-1], path)
+		if !processed {
+			return value, false, err
+		}
+
+		return decrypted, true, nil
+	}
+
+	if len(value) > 1 && value[0] == '"' && isEncryptedValue(value[1:len(value)-1]) {
+		return value, false, nil
+	}
+
+	encrypted, processed, err := e.processRawValue(value, path)
+	if !processed {
+		return value, false, err
+	}
+
+	return `"` + encrypted + `"`, true, nil
+}
+
+// tomlKeyEnd returns the index of the `=` separating a key from its value, or -1 if there is none.
+func tomlKeyEnd(text string) int {
+	for idx := 0; idx < len(text); idx++ {
+		switch text[idx] {
+		case '"', '\'':
+			end := tomlStringEnd(text, idx)
+			if end < 0 {
+				return -1
+			}
+
+			idx = end - 1
+		case '=':
+			return idx
+		}
+	}
+
+	return -1
+}
+
+// closingTOMLBracket returns the index after the brackets closing a table header, or -1 if they are not closed.
+func closingTOMLBracket(header string) int {
+	for idx := 0; idx < len(header); idx++ {
+		switch header[idx] {
+		case '"', '\'':
+			end := tomlStringEnd(header, idx)
+			if end < 0 {
+				return -1
+			}
+
+			idx = end - 1
+		case ']':
+			if strings.HasPrefix(header[idx:], "]]") {
+				return idx + 2
+			}
+
+			return idx + 1
+		}
+	}
+
+	return -1
+}
+
+// splitTOMLKey splits a dotted key into its parts, removing blanks and quotes.
+func splitTOMLKey(key string) []string {
+	var (
+		parts []string
+		part  strings.Builder
+		quote byte
+	)
+
+	for idx := range len(key) {
+		char := key[idx]
+
+		switch {
+		case quote != 0 && char == quote:
+			quote = 0
+		case quote != 0:
+			part.WriteByte(char)
+		case char == '"' || char == '\'':
+			quote = char
+		case char == '.':
+			parts = append(parts, strings.TrimSpace(part.String()))
+			part.Reset()
+		case char != ' ' && char != '\t':
+			part.WriteByte(char)
+		}
+	}
+
+	return append(parts, strings.TrimSpace(part.String()))
+}
+
+// tomlValueEnd returns the length of the value at the start of rest, without the blanks and comment after it.
+// It returns -1 if the value continues on the next line, as multi-line strings and arrays do.
+func tomlValueEnd(rest string) int {
+	depth := 0
+
+	for idx := 0; idx < len(rest); idx++ {
+		switch rest[idx] {
+		case '"', '\'':
+			end := tomlStringEnd(rest, idx)
+			if end < 0 {
+				return -1
+			}
+
+			idx = end - 1
+		case '[', '{':
+			depth++
+		case ']', '}':
+			depth--
+		case '#', '\n':
+			if depth > 0 {
+				// Comments and line breaks are allowed within arrays
+				if next := strings.IndexByte(rest[idx:], '\n'); next >= 0 {
+					idx += next
+
+					continue
+				}
+
+				return -1
+			}
+
+			return len(strings.TrimRight(rest[:idx], " \t\r"))
+		}
+	}
+
+	if depth > 0 {
+		return -1
+	}
+
+	return len(strings.TrimRight(rest, " \t\r\n"))
+}
+
+// tomlMemberEnd returns the length of the value of an inline table member at the start of text,
+// without the blanks before the comma or brace ending it, or -1 if the value is not terminated.
+func tomlMemberEnd(text string) int {
+	depth := 0
+
+	for idx := 0; idx < len(text); idx++ {
+		switch text[idx] {
+		case '"', '\'':
+			end := tomlStringEnd(text, idx)
+			if end < 0 {
+				return -1
+			}
+
+			idx = end - 1
+		case '[', '{':
+			depth++
+		case ']':
+			depth--
+		case ',', '}':
+			if depth == 0 {
+				return len(strings.TrimRight(text[:idx], " \t"))
+			}
+
+			if text[idx] == '}' {
+				depth--
+			}
+		}
+	}
+
+	return -1
+}
+
+// tomlStringEnd returns the index after the string starting at start, or -1 if it is not closed.
+// Backslashes escape characters only within basic strings, and multi-line strings may span lines.
+func tomlStringEnd(text string, start int) int {
+	quote := text[start]
+	delim := text[start : start+1]
+
+	if strings.HasPrefix(text[start:], strings.Repeat(delim, 3)) {
+		delim = strings.Repeat(delim, 3)
+	}
+
+	for idx := start + len(delim); idx < len(text); idx++ {
+		switch {
+		case text[idx] == '\\' && quote == '"':
+			idx++
+		case len(delim) == 1 && text[idx] == '\n':
+			return -1
+		case strings.HasPrefix(text[idx:], delim):
+			end := idx + len(delim)
+
+			// A multi-line string may end with up to two quotes of its own
+			for extra := 0; extra < 2 && len(delim) == 3 && end < len(text) && text[end] == quote; extra++ {
+				end++
+			}
+
+			return end
+		}
+	}
+
+	return -1
+}
@@ -0,0 +1,77 @@
+package encrypt
+
+import (
+	"bytes"
+	"errors"
+	"testing"
+)
+
+func TestTOMLInlineTable(t *testing.T) {
+	t.Parallel()
+
+	input := []byte(`limits = { memory = "1Gi", cpu = 2, nested = { key = "hunter2" } } # quota` + "\n")
+
+	tests := []struct {
+		name      string
+		selectors []string
+		hidden    []string
+		shown     []string
+	}{
+		{name: "member", selectors: []string{"limits.memory"}, hidden: []string{"1Gi"}, shown: []string{"cpu = 2", "hunter2"}},
+		{name: "nested", selectors: []string{"limits.nested.key"}, hidden: []string{"hunter2"}, shown: []string{"1Gi", "cpu = 2"}},
+		{name: "wildcard", selectors: []string{"limits.*"}, hidden: []string{"1Gi", "hunter2"}, shown: []string{"cpu = \""}},
+		{name: "whole", selectors: []string{"limits"}, hidden: []string{"memory", "cpu"}, shown: []string{"limits = \"", "# quota"}},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			t.Parallel()
+
+			selector, err := NewSelector(test.selectors, "")
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			encryptor := Encryptor{Keyring: testKeyring(t, randomizedKeyLen), Mode: TOML, Selector: selector, Parallel: 1}
+
+			encrypted := roundTrip(t, encryptor, input, input)
+
+			for _, hidden := range test.hidden {
+				if bytes.Contains(encrypted, []byte(hidden)) {
+					t.Fatalf("ciphertext holds %q: %s", hidden, encrypted)
+				}
+			}
+
+			for _, shown := range test.shown {
+				if !bytes.Contains(encrypted, []byte(shown)) {
+					t.Fatalf("ciphertext lacks %q: %s", shown, encrypted)
+				}
+			}
+		})
+	}
+}
+
+func TestTOMLRejects(t *testing.T) {
+	t.Parallel()
+
+	encryptor := Encryptor{Keyring: testKeyring(t, randomizedKeyLen), Mode: TOML, Parallel: 1}
+
+	tests := []struct {
+		name  string
+		input string
+	}{
+		{name: "missing value", input: "[database]\npassword\n"},
+		{name: "unterminated string", input: "password = \"\"\"hunter2\n"},
+		{name: "unterminated inline table", input: "limits = { memory = \"1Gi\", cpu = [1, 2 }\n"},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			t.Parallel()
+
+			if _, err := process(t, encryptor, Encrypt, []byte(test.input)); !errors.Is(err, ErrProcessing) {
+				t.Fatalf("got %v, want %v", err, ErrProcessing)
+			}
+		})
+	}
+}
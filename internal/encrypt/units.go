@@ -0,0 +1,157 @@
+package encrypt
+
+import (
+	"fmt"
+	"strings"
+)
+
+// lineUnit is a run of input lines that is encrypted or decrypted as one.
+type lineUnit struct {
+	// lines are the original input lines
+	lines []string
+
+	// encrypt reports whether the unit is marked for encryption
+	encrypt bool
+
+	// indent is the indentation kept in front of the encrypted unit, set for blocks
+	indent string
+}
+
+// text returns the lines of the unit joined by newlines.
+func (u lineUnit) text() string {
+	return strings.Join(u.lines, "\n")
+}
+
+// groupLines splits the input lines into units.
+// When encrypting, lines ending in the encrypt directive become single-line units, and the lines enclosed
+// by the begin and end directives (including the directives themselves) become one block unit,
+// so that they decrypt back to the exact original lines.
+// The encrypted block keeps the indentation of its begin directive.
+// With pairStandalone, a line consisting only of the encrypt directive is grouped with the line following it.
+// When decrypting, every line is a unit of its own.
+func (e *Encryptor) groupLines(lines []string, pairStandalone bool) ([]lineUnit, error) {
+	units := make([]lineUnit, 0, len(lines))
+
+	for idx := 0; idx < len(lines); idx++ {
+		line := lines[idx]
+		trimmed := strings.TrimSpace(line)
+
+		switch {
+		case e.Operation != Encrypt:
+			units = append(units, lineUnit{lines: lines[idx : idx+1]})
+		case e.Directives.Begin != "" && trimmed == e.Directives.Begin:
+			end, err := e.blockEnd(lines, idx)
+			if err != nil {
+				return nil, err
+			}
+
+			indent := line[:len(line)-len(strings.TrimLeft(line, " \t"))]
+
+			units = append(units, lineUnit{lines: lines[idx : end+1], encrypt: true, indent: indent})
+			idx = end
+		case e.Directives.End != "" && trimmed == e.Directives.End:
+			return nil, fmt.Errorf("%w: line %d: %q without %q", ErrProcessing, idx+1, e.Directives.End, e.Directives.Begin)
+		case pairStandalone && trimmed == e.Directives.Encrypt && idx+1 < len(lines):
+			units = append(units, lineUnit{lines: lines[idx : idx+2], encrypt: true})
+			idx++
+		default:
+			units = append(units, lineUnit{lines: lines[idx : idx+1], encrypt: strings.HasSuffix(line, e.Directives.Encrypt)})
+		}
+	}
+
+	return units, nil
+}
+
+// blockEnd returns the index of the end directive closing the block that begins at index begin.
+func (e *Encryptor) blockEnd(lines []string, begin int) (int, error) {
+	for idx := begin + 1; idx < len(lines); idx++ {
+		switch strings.TrimSpace(lines[idx]) {
+		case e.Directives.End:
+			return idx, nil
+		case e.Directives.Begin:
+			return 0, fmt.Errorf("%w: line %d: nested %q", ErrProcessing, idx+1, e.Directives.Begin)
+		}
+	}
+
+	return 0, fmt.Errorf("%w: line %d: %q is never closed by %q",
+		ErrProcessing, begin+1, e.Directives.Begin, e.Directives.End)
+}
+
+// processUnit encrypts or decrypts a unit according to the directives.
+// It returns the output for the unit and whether it was processed.
+func (e *Encryptor) processUnit(unit lineUnit) (string, bool, error) {
+	text := unit.text()
+
+	switch e.Operation {
+	case Encrypt:
+		if !unit.encrypt {
+			return text, false, nil
+		}
+
+		key, value := unit.indent, text
+
+		if e.Values && len(unit.lines) == 1 && unit.indent == "" {
+			key, value = e.splitValue(text)
+		}
+
+		encrypted, err := e.encryptData([]byte(value))
+		if err != nil {
+			return "", false, err
+		}
+
+		return fmt.Sprintf("%s%s: %s", key, e.Directives.Decrypt, string(encrypted)), true, nil
+	case Decrypt:
+		key, encoded, ok := e.splitCiphertext(text)
+		if !ok {
+			return text, false, nil
+		}
+
+		decrypted, err := e.decryptData([]byte(encoded))
+		if err != nil {
+			return "", false, err
+		}
+
+		return key + string(decrypted), true, nil
+	default:
+		return text, false, nil
+	}
+}
+
+// splitValue splits a line into its key and value at the first separator.
+// The key keeps the separator and any blanks following it, so that only the value is encrypted.
+// Lines without a separator are returned as value only.
+func (e *Encryptor) splitValue(line string) (string, string) {
+	idx := strings.IndexAny(strings.TrimSuffix(line, e.Directives.Encrypt), e.Directives.Separators)
+	if idx < 0 {
+		return "", line
+	}
+
+	rest := line[idx+1:]
+	value := strings.TrimLeft(rest, " \t")
+
+	return line[:len(line)-len(value)], value
+}
+
+// splitCiphertext locates the ciphertext in a line.
+// It returns the key kept in front of an encrypted value, the base64 ciphertext, and whether the line holds one.
+// Whole lines and blocks have no key, their indentation is restored from the decrypted lines.
+func (e *Encryptor) splitCiphertext(line string) (string, string, bool) {
+	marker := e.Directives.Decrypt + ": "
+
+	if content := strings.TrimLeft(line, " \t"); strings.HasPrefix(content, marker) {
+		return "", strings.TrimPrefix(content, marker), true
+	}
+
+	idx := strings.Index(line, marker)
+	if idx <= 0 {
+		return "", "", false
+	}
+
+	// An encrypted value follows a key ending in a separator
+	key := strings.TrimRight(line[:idx], " \t")
+	if key == "" || !strings.ContainsAny(key[len(key)-1:], e.Directives.Separators) {
+		return "", "", false
+	}
+
+	return line[:idx], line[idx+len(marker):], true
+}
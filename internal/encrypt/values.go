@@ -0,0 +1,109 @@
+package encrypt
+
+import (
+	"encoding/base64"
+	"fmt"
+	"strings"
+)
+
+// valueTypeSeparator separates an encrypted value from the type of its plaintext.
+// It is not part of the base64 alphabet, so it cannot occur in the envelope itself.
+const valueTypeSeparator = ":"
+
+// valueTypeDelimiter delimits the type sealed in front of the plaintext of a typed value.
+const valueTypeDelimiter = "\x00"
+
+// encryptValue encrypts a scalar value of a structured document together with its type, empty for strings.
+// The type is sealed in front of the plaintext, so that it is authenticated with it, and values that are
+// not strings also show it after the envelope, e.g. `R09DUlk...:int`, for reviewers.
+func (e *Encryptor) encryptValue(value, kind string) (string, error) {
+	encrypted, err := e.encryptData([]byte(valueTypeDelimiter + kind + valueTypeDelimiter + value))
+	if err != nil {
+		return "", err
+	}
+
+	if kind == "" {
+		return string(encrypted), nil
+	}
+
+	return string(encrypted) + valueTypeSeparator + kind, nil
+}
+
+// decryptValue decrypts a value produced by encryptValue, returning the plaintext and its sealed type.
+// The type shown after the envelope must match the sealed one.
+func (e *Encryptor) decryptValue(value string) (string, string, error) {
+	encoded, shown, _ := strings.Cut(value, valueTypeSeparator)
+
+	decrypted, err := e.decryptData([]byte(encoded))
+	if err != nil {
+		return "", "", err
+	}
+
+	sealed, ok := strings.CutPrefix(string(decrypted), valueTypeDelimiter)
+	if !ok {
+		return "", "", fmt.Errorf("%w: value is not sealed with its type", ErrProcessing)
+	}
+
+	kind, plaintext, ok := strings.Cut(sealed, valueTypeDelimiter)
+	if !ok {
+		return "", "", fmt.Errorf("%w: value is not sealed with its type", ErrProcessing)
+	}
+
+	if kind != shown {
+		return "", "", fmt.Errorf("%w: value shows type %q, but was sealed as %q", ErrProcessing, shown, kind)
+	}
+
+	return plaintext, kind, nil
+}
+
+// isEncryptedValue reports whether value was produced by encryptValue or processRawValue,
+// that is whether it holds a base64 envelope with a valid header.
+func isEncryptedValue(value string) bool {
+	encoded, _, _ := strings.Cut(value, valueTypeSeparator)
+
+	data, err := base64.StdEncoding.DecodeString(encoded)
+	if err != nil {
+		return false
+	}
+
+	_, err = parseEnvelopeHeader(data)
+
+	return err == nil
+}
+
+// processRawValue encrypts a selected value exactly as written, or decrypts an encrypted one.
+// Line-based formats keep literals, quotes included, so that decryption restores them byte for byte;
+// as the literal is spliced back as it is, it carries no type.
+// It returns the replacement and whether the value was processed.
+func (e *Encryptor) processRawValue(value string, path []string) (string, bool, error) {
+	if value == "" {
+		return value, false, nil
+	}
+
+	switch e.Operation {
+	case Encrypt:
+		if isEncryptedValue(value) || !e.Selector.Matches(path) {
+			return value, false, nil
+		}
+
+		encrypted, err := e.encryptData([]byte(value))
+		if err != nil {
+			return "", false, fmt.Errorf("encrypting %q: %w", strings.Join(path, "."), err)
+		}
+
+		return string(encrypted), true, nil
+	case Decrypt:
+		if !isEncryptedValue(value) {
+			return value, false, nil
+		}
+
+		decrypted, err := e.decryptData([]byte(value))
+		if err != nil {
+			return "", false, fmt.Errorf("decrypting %q: %w", strings.Join(path, "."), err)
+		}
+
+		return string(decrypted), true, nil
+	}
+
+	return value, false, nil
+}
@@ -0,0 +1,170 @@
+package encrypt
+
+import (
+	"bytes"
+	"errors"
+	"testing"
+)
+
+// structuredTests are documents of the structured modes, with the plaintext that must not show in their
+// ciphertext and the text that must stay in the clear, in this order.
+//
+//nolint:gochecknoglobals // these globals are acceptable
+var structuredTests = []struct {
+	name   string
+	mode   Mode
+	input  string
+	hidden []string
+	kept   []string
+}{
+	{
+		name: "yaml",
+		mode: YAML,
+		input: `# database settings
+database:
+  user: admin # the login
+  password: "hunter2\ttabbed"
+  port: 5432
+  ratio: 0.5
+  enabled: true
+  unset: null
+  hosts:
+    - b.example.com
+    - a.example.com
+`,
+		hidden: []string{"admin", "hunter2", "5432", "example.com"},
+		kept: []string{
+			"# database settings\ndatabase:\n  user: ", " # the login\n  password: ", "\n  port: ", ":int\n  ratio: ",
+			":float\n  enabled: ", ":bool\n  unset: ", ":null\n  hosts:\n    - ", "\n    - ",
+		},
+	},
+	{
+		name: "json",
+		mode: JSON,
+		input: `{
+  "zeta": "café \"quoted\" \\ <tag>",
+  "alpha": 1.50e3,
+  "enabled": false,
+  "unset": null,
+  "hosts": ["b.example.com", "a.example.com"],
+  "nested": {"password": "hunter2"}
+}
+`,
+		hidden: []string{`é`, "quoted", "1.50e3", "false", "example.com", "hunter2"},
+		kept: []string{
+			"{\n  \"zeta\": \"", "\",\n  \"alpha\": \"", ":number\",\n  \"enabled\": \"", ":bool\",\n  \"unset\": \"",
+			":null\",\n  \"hosts\": [\"", "\", \"", "\"],\n  \"nested\": {\"password\": \"", "\"}\n}\n",
+		},
+	},
+	{
+		name: "dotenv",
+		mode: Dotenv,
+		input: `# database settings
+export DB_USER=admin
+DB_PASSWORD="hunter2 \"quoted\"\n" # the password
+DB_PORT=5432
+
+SINGLE='literal $HOME'
+EMPTY=
+`,
+		hidden: []string{"admin", "hunter2", "5432", "$HOME"},
+		kept: []string{
+			"# database settings\nexport DB_USER=", "\nDB_PASSWORD=", " # the password\nDB_PORT=", "\n\nSINGLE=",
+			"\nEMPTY=\n",
+		},
+	},
+	{
+		name: "toml",
+		mode: TOML,
+		input: `# service settings
+title = "demo \"quoted\" é"
+
+[database]
+password = 'hunter2' # the password
+port = 5432
+enabled = true
+hosts = [
+  "b.example.com", # replica
+  "a.example.com",
+]
+limits = { memory = "1Gi", cpu = 2 }
+
+[[servers]]
+token = """
+multi-line secret"""
+`,
+		hidden: []string{"quoted", "hunter2", "5432", "example.com", "replica", "1Gi", "multi-line secret"},
+		kept: []string{
+			"# service settings\ntitle = \"", "\"\n\n[database]\npassword = \"", "\" # the password\nport = \"",
+			"\"\nenabled = \"", "\"\nhosts = \"", "\"\nlimits = \"", "\"\n\n[[servers]]\ntoken = \"", "\"\n",
+		},
+	},
+	{
+		name: "ini",
+		mode: INI,
+		input: `; global settings
+name = demo
+
+[database]
+# credentials
+password = hunter2 ; rotated yearly
+token: "quoted ; not a comment" # kept
+color=#fff
+empty = ; nothing yet
+url = http://example.com/#anchor
+`,
+		hidden: []string{"demo", "hunter2", "not a comment", "#fff", "example.com"},
+		kept: []string{
+			"; global settings\nname = ", "\n\n[database]\n# credentials\npassword = ", " ; rotated yearly\ntoken: ",
+			" # kept\ncolor=", "\nempty = ; nothing yet\nurl = ", "\n",
+		},
+	},
+}
+
+func TestStructuredRoundTrip(t *testing.T) {
+	t.Parallel()
+
+	for _, test := range structuredTests {
+		t.Run(test.name, func(t *testing.T) {
+			t.Parallel()
+
+			encryptor := Encryptor{Keyring: testKeyring(t, randomizedKeyLen), Mode: test.mode, Parallel: 1}
+
+			encrypted := roundTrip(t, encryptor, []byte(test.input), []byte(test.input))
+
+			for _, hidden := range test.hidden {
+				if bytes.Contains(encrypted, []byte(hidden)) {
+					t.Fatalf("ciphertext holds %q: %s", hidden, encrypted)
+				}
+			}
+
+			// Comments, keys and layout stay in place, in their order
+			rest := encrypted
+
+			for _, kept := range test.kept {
+				idx := bytes.Index(rest, []byte(kept))
+				if idx < 0 {
+					t.Fatalf("ciphertext lacks %q in order: %s", kept, encrypted)
+				}
+
+				rest = rest[idx+len(kept):]
+			}
+
+			// Values already encrypted are left alone
+			again, err := process(t, encryptor, Encrypt, encrypted)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			if !bytes.Equal(again, encrypted) {
+				t.Fatalf("encrypting again changed the ciphertext: %s", again)
+			}
+
+			other := Encryptor{Keyring: testKeyring(t, randomizedKeyLen), Mode: test.mode, Parallel: 1}
+
+			if _, err := process(t, other, Decrypt, encrypted); !errors.Is(err, ErrKeyMismatch) {
+				t.Fatalf("got %v, want %v", err, ErrKeyMismatch)
+			}
+		})
+	}
+}
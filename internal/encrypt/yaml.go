@@ -0,0 +1,173 @@
+package encrypt
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"slices"
+	"strconv"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+)
+
+// yamlIndent is the indentation used when writing YAML documents.
+const yamlIndent = 2
+
+// yamlKinds are the tags of the scalars that can be encrypted, without their `!!` prefix.
+// Strings have no kind, and other tags are refused, so that decryption never restores an unexpected tag.
+//
+//nolint:gochecknoglobals // these globals are acceptable
+var yamlKinds = []string{"", "int", "float", "bool", "null", "binary", "timestamp"}
+
+// processYAML encrypts or decrypts the selected scalar values of a YAML stream.
+// Keys, comments and ordering are kept, only the values change. The stream is written anew,
+// so indentation is normalized and blank lines are dropped.
+// Returns true if any value was encrypted or decrypted.
+func (e *Encryptor) processYAML(reader io.Reader, writer io.Writer) (bool, error) {
+	var documents []*yaml.Node
+
+	decoder := yaml.NewDecoder(reader)
+
+	for {
+		var document yaml.Node
+
+		err := decoder.Decode(&document)
+		if errors.Is(err, io.EOF) {
+			break
+		}
+
+		if err != nil {
+			return false, fmt.Errorf("%w: parsing yaml: %w", ErrProcessing, err)
+		}
+
+		documents = append(documents, &document)
+	}
+
+	var anyProcessed bool
+
+	for _, document := range documents {
+		processed, err := e.processYAMLNode(document, nil)
+		if err != nil {
+			return false, err
+		}
+
+		anyProcessed = anyProcessed || processed
+	}
+
+	encoder := yaml.NewEncoder(writer)
+	encoder.SetIndent(yamlIndent)
+
+	for _, document := range documents {
+		if err := encoder.Encode(document); err != nil {
+			return false, fmt.Errorf("%w: writing yaml: %w", ErrProcessing, err)
+		}
+	}
+
+	if err := encoder.Close(); err != nil {
+		return false, fmt.Errorf("%w: writing yaml: %w", ErrProcessing, err)
+	}
+
+	return anyProcessed, nil
+}
+
+// processYAMLNode walks a node, processing the scalar values below it.
+// Aliases are skipped, the values they refer to are processed where their anchor is defined.
+func (e *Encryptor) processYAMLNode(node *yaml.Node, path []string) (bool, error) {
+	var anyProcessed bool
+
+	switch node.Kind {
+	case yaml.DocumentNode:
+		for _, child := range node.Content {
+			processed, err := e.processYAMLNode(child, path)
+			if err != nil {
+				return false, err
+			}
+
+			anyProcessed = anyProcessed || processed
+		}
+	case yaml.MappingNode:
+		for idx := 0; idx+1 < len(node.Content); idx += 2 {
+			key, value := node.Content[idx], node.Content[idx+1]
+
+			// Merge keys only pull in values defined elsewhere
+			if key.ShortTag() == "!!merge" {
+				continue
+			}
+
+			processed, err := e.processYAMLNode(value, append(slices.Clone(path), key.Value))
+			if err != nil {
+				return false, err
+			}
+
+			anyProcessed = anyProcessed || processed
+		}
+	case yaml.SequenceNode:
+		for idx, child := range node.Content {
+			processed, err := e.processYAMLNode(child, append(slices.Clone(path), strconv.Itoa(idx)))
+			if err != nil {
+				return false, err
+			}
+
+			anyProcessed = anyProcessed || processed
+		}
+	case yaml.ScalarNode:
+		return e.processYAMLScalar(node, path)
+	case yaml.AliasNode:
+	}
+
+	return anyProcessed, nil
+}
+
+// processYAMLScalar encrypts a selected scalar, or decrypts an encrypted one.
+// The scalar keeps its style, and values that are not strings get their tag back when decrypted.
+// Only the standard tags listed in yamlKinds are encrypted and restored.
+func (e *Encryptor) processYAMLScalar(node *yaml.Node, path []string) (bool, error) {
+	const strTag = "!!str"
+
+	switch e.Operation {
+	case Encrypt:
+		if isEncryptedValue(node.Value) || !e.Selector.Matches(path) {
+			return false, nil
+		}
+
+		var kind string
+
+		if tag := node.ShortTag(); tag != strTag {
+			kind = strings.TrimPrefix(tag, "!!")
+		}
+
+		if !slices.Contains(yamlKinds, kind) {
+			return false, fmt.Errorf("%w: encrypting %q: values tagged %s cannot be encrypted",
+				ErrProcessing, strings.Join(path, "."), node.ShortTag())
+		}
+
+		value, err := e.encryptValue(node.Value, kind)
+		if err != nil {
+			return false, fmt.Errorf("encrypting %q: %w", strings.Join(path, "."), err)
+		}
+
+		node.Value, node.Tag = value, strTag
+	case Decrypt:
+		if !isEncryptedValue(node.Value) {
+			return false, nil
+		}
+
+		value, kind, err := e.decryptValue(node.Value)
+		if err != nil {
+			return false, fmt.Errorf("decrypting %q: %w", strings.Join(path, "."), err)
+		}
+
+		if !slices.Contains(yamlKinds, kind) {
+			return false, fmt.Errorf("%w: decrypting %q: unexpected type %q", ErrProcessing, strings.Join(path, "."), kind)
+		}
+
+		node.Value, node.Tag = value, strTag
+
+		if kind != "" {
+			node.Tag = "!!" + kind
+		}
+	}
+
+	return true, nil
+}
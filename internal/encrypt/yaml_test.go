@@ -0,0 +1,105 @@
+package encrypt
+
+import (
+	"bytes"
+	"errors"
+	"strings"
+	"testing"
+)
+
+func TestYAMLLayout(t *testing.T) {
+	t.Parallel()
+
+	// The document is written anew, which normalizes indentation and drops blank lines
+	input := []byte("a:\n    b: 1\n\nc:   [x, y]\n")
+	want := []byte("a:\n  b: 1\nc: [x, y]\n")
+
+	selector, err := NewSelector([]string{"none"}, "")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	encryptor := Encryptor{Keyring: testKeyring(t, randomizedKeyLen), Mode: YAML, Selector: selector, Parallel: 1}
+
+	encrypted, err := process(t, encryptor, Encrypt, input)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if !bytes.Equal(encrypted, want) {
+		t.Fatalf("got %q, want %q", encrypted, want)
+	}
+}
+
+func TestYAMLSelector(t *testing.T) {
+	t.Parallel()
+
+	selector, err := NewSelector([]string{"database.password"}, "")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	input := []byte("database:\n  user: admin\n  password: hunter2\n")
+
+	encryptor := Encryptor{Keyring: testKeyring(t, 32), Mode: YAML, Selector: selector, Parallel: 1}
+
+	encrypted := roundTrip(t, encryptor, input, input)
+
+	if !bytes.Contains(encrypted, []byte("user: admin")) || bytes.Contains(encrypted, []byte("hunter2")) {
+		t.Fatalf("unexpected selection: %s", encrypted)
+	}
+}
+
+func TestYAMLRejects(t *testing.T) {
+	t.Parallel()
+
+	encryptor := Encryptor{Keyring: testKeyring(t, 32), Mode: YAML, Parallel: 1}
+
+	encrypted, err := process(t, encryptor, Encrypt, []byte("port: 5432\nname: admin\n"))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	name := strings.TrimSuffix(strings.SplitAfter(string(encrypted), "name: ")[1], "\n")
+
+	// tamper changes a character in the middle of the envelope of name
+	tamper := func() string {
+		tampered := []byte(name)
+		if tampered[len(tampered)/2] == 'A' {
+			tampered[len(tampered)/2] = 'B'
+		} else {
+			tampered[len(tampered)/2] = 'A'
+		}
+
+		return strings.Replace(string(encrypted), name, string(tampered), 1)
+	}
+
+	tests := []struct {
+		name  string
+		input string
+	}{
+		{name: "retyped", input: strings.Replace(string(encrypted), ":int", ":binary", 1)},
+		{name: "type dropped", input: strings.Replace(string(encrypted), ":int", "", 1)},
+		{name: "type added", input: strings.Replace(string(encrypted), name, name+":int", 1)},
+		{name: "unexpected type", input: strings.Replace(string(encrypted), ":int", ":str", 1)},
+		{name: "envelope", input: tamper()},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			t.Parallel()
+
+			if _, err := process(t, encryptor, Decrypt, []byte(test.input)); !errors.Is(err, ErrProcessing) {
+				t.Fatalf("got %v, want %v", err, ErrProcessing)
+			}
+		})
+	}
+
+	t.Run("custom tag", func(t *testing.T) {
+		t.Parallel()
+
+		if _, err := process(t, encryptor, Encrypt, []byte("secret: !vault hunter2\n")); !errors.Is(err, ErrProcessing) {
+			t.Fatalf("got %v, want %v", err, ErrProcessing)
+		}
+	})
+}
@@ -0,0 +1,188 @@
+package logic
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"os/exec"
+	"os/signal"
+	"path/filepath"
+	"strings"
+
+	"github.com/idelchi/gocry/internal/config"
+	"github.com/idelchi/gocry/internal/encrypt"
+	"github.com/idelchi/gogen/pkg/printer"
+)
+
+// errEditor indicates that the editor failed, leaving the file unchanged.
+var errEditor = errors.New("editor failed")
+
+// tmpfsDir is the preferred directory for plaintext temporary files, as it is memory-backed where it exists.
+const tmpfsDir = "/dev/shm"
+
+// Edit decrypts a file into a private temporary file, opens it in $EDITOR and re-encrypts it on exit.
+// Whole files are re-encrypted the way they were encrypted. Files left unchanged are not rewritten,
+// and the temporary file is shredded in any case. A file that does not exist yet is created.
+func Edit(cfg *config.Config) error {
+	encryptor, err := newEncryptor(cfg)
+	if err != nil {
+		return err
+	}
+
+	ciphertext, err := os.ReadFile(filepath.Clean(cfg.File))
+	if err != nil && !errors.Is(err, fs.ErrNotExist) {
+		return fmt.Errorf("reading %q: %w", cfg.File, err)
+	}
+
+	var plaintext bytes.Buffer
+
+	if len(ciphertext) > 0 {
+		if cfg.Mode == encrypt.File {
+			if err := encryptor.MatchEnvelope(ciphertext); err != nil {
+				return fmt.Errorf("editing %q: %w", cfg.File, err)
+			}
+		}
+
+		if _, err := encryptor.Process(bytes.NewReader(ciphertext), &plaintext); err != nil {
+			return fmt.Errorf("processing data: %w", err)
+		}
+	}
+
+	// Check that the content can be encrypted again before any edits are made
+	if encryptor.Keyring != nil {
+		if err := validateKeyring(encryptor.Keyring, encrypt.Encrypt, encryptor.Deterministic); err != nil {
+			return err
+		}
+	}
+
+	edited, err := editPlaintext(cfg.File, plaintext.Bytes())
+	if err != nil {
+		return err
+	}
+
+	if len(ciphertext) > 0 && bytes.Equal(edited, plaintext.Bytes()) {
+		if !cfg.Quiet {
+			printer.Stderrln("unchanged: %q", cfg.File)
+		}
+
+		return nil
+	}
+
+	encryptor.Operation = encrypt.Encrypt
+
+	var out bytes.Buffer
+
+	if _, err := encryptor.Process(bytes.NewReader(edited), &out); err != nil {
+		return fmt.Errorf("processing data: %w", err)
+	}
+
+	if err := writeFileAtomic(cfg.File, out.Bytes(), privatePerm); err != nil {
+		return err
+	}
+
+	if !cfg.Quiet {
+		printer.Stderrln("edited: %q", cfg.File)
+	}
+
+	return nil
+}
+
+// editPlaintext writes the plaintext to a private temporary file, runs the editor on it and returns the result.
+// The temporary file keeps the extension of file for syntax highlighting, and is shredded before returning.
+func editPlaintext(file string, plaintext []byte) (edited []byte, err error) {
+	dir := os.TempDir()
+	if info, err := os.Stat(tmpfsDir); err == nil && info.IsDir() {
+		dir = tmpfsDir
+	}
+
+	// CreateTemp creates the file with 0600 permissions
+	tmp, err := os.CreateTemp(dir, "gocry-*"+filepath.Ext(file))
+	if err != nil {
+		return nil, fmt.Errorf("creating temporary file: %w", err)
+	}
+
+	defer func() {
+		if shredErr := shred(tmp.Name()); shredErr != nil && err == nil {
+			err = shredErr
+		}
+	}()
+
+	if _, err := tmp.Write(plaintext); err != nil {
+		_ = tmp.Close()
+
+		return nil, fmt.Errorf("writing temporary file: %w", err)
+	}
+
+	if err := tmp.Close(); err != nil {
+		return nil, fmt.Errorf("closing temporary file: %w", err)
+	}
+
+	if err := runEditor(tmp.Name()); err != nil {
+		return nil, err
+	}
+
+	edited, err = os.ReadFile(tmp.Name())
+	if err != nil {
+		return nil, fmt.Errorf("reading temporary file: %w", err)
+	}
+
+	return edited, nil
+}
+
+// runEditor runs $EDITOR (or vi) on path, which may hold arguments as in `code --wait`.
+// Signals sent to gocry while the editor runs are left to the editor, and abort the edit once it exits.
+func runEditor(path string) error {
+	editor := strings.Fields(os.Getenv("EDITOR"))
+	if len(editor) == 0 {
+		editor = []string{"vi"}
+	}
+
+	signals := make(chan os.Signal, 1)
+	signal.Notify(signals, forwardedSignals...)
+
+	defer signal.Stop(signals)
+
+	command := exec.Command(editor[0], append(editor[1:], path)...) //nolint:gosec // running the editor is the point
+	command.Stdin, command.Stdout, command.Stderr = os.Stdin, os.Stdout, os.Stderr
+
+	// The error is not wrapped, as gocry would otherwise exit with the exit code of the editor
+	if err := command.Run(); err != nil {
+		return fmt.Errorf("%w: %q: %v, file left unchanged", errEditor, editor[0], err) //nolint:errorlint // intended
+	}
+
+	select {
+	case sig := <-signals:
+		return fmt.Errorf("%w: aborted by signal %v, file left unchanged", errEditor, sig)
+	default:
+		return nil
+	}
+}
+
+// shred overwrites the file at path with zeros in place before removing it.
+// On copy-on-write or journaling file systems the old blocks may survive, which is why tmpfs is preferred.
+func shred(path string) error {
+	file, err := os.OpenFile(path, os.O_WRONLY, 0)
+	if err == nil {
+		var info os.FileInfo
+
+		if info, err = file.Stat(); err == nil {
+			if _, err = file.Write(make([]byte, info.Size())); err == nil {
+				err = file.Sync()
+			}
+		}
+
+		_ = file.Close()
+	}
+
+	if removeErr := os.Remove(path); removeErr != nil {
+		return fmt.Errorf("removing temporary file: %w", removeErr)
+	}
+
+	if err != nil {
+		return fmt.Errorf("shredding temporary file: %w", err)
+	}
+
+	return nil
+}
@@ -0,0 +1,129 @@
+package logic
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"os"
+	"os/exec"
+	"os/signal"
+	"path/filepath"
+	"syscall"
+
+	"github.com/idelchi/gocry/internal/config"
+	"github.com/idelchi/gocry/internal/dotenv"
+)
+
+// signalExitBase is added to the number of the signal that killed a command to give its exit code.
+const signalExitBase = 128
+
+// forwardedSignals are the signals relayed to the command run by Exec.
+var forwardedSignals = []os.Signal{os.Interrupt, syscall.SIGTERM, syscall.SIGHUP, syscall.SIGQUIT}
+
+// ExitError reports a command run by Exec that failed, and the exit code gocry should exit with.
+type ExitError struct {
+	// Code is the exit code of the command, or 128 plus the number of the signal that killed it
+	Code int
+
+	// Err is the error returned by the command
+	Err error
+}
+
+// Error returns the error of the command.
+func (e *ExitError) Error() string {
+	return e.Err.Error()
+}
+
+// Unwrap returns the error of the command.
+func (e *ExitError) Unwrap() error {
+	return e.Err
+}
+
+// newExitError returns the ExitError for a command that failed with err, or nil if it did not run to completion.
+func newExitError(name string, err error) error {
+	var exitErr *exec.ExitError
+	if !errors.As(err, &exitErr) {
+		return nil
+	}
+
+	code := exitErr.ExitCode()
+
+	// Shells report commands killed by a signal in the same way
+	if status, ok := exitErr.Sys().(syscall.WaitStatus); ok && status.Signaled() {
+		code = signalExitBase + int(status.Signal())
+	}
+
+	if code <= 0 {
+		code = 1
+	}
+
+	return &ExitError{Code: code, Err: fmt.Errorf("running %q: %w", name, err)}
+}
+
+// Exec decrypts a dotenv file in memory and runs a command with its variables added to the environment.
+// The plaintext is never written to disk. Signals are forwarded to the command, and a failing command
+// is reported as an *ExitError carrying its exit code.
+func Exec(cfg *config.Config) error {
+	encryptor, err := newEncryptor(cfg)
+	if err != nil {
+		return err
+	}
+
+	// The command inherits stdin, so the file is always read from its path
+	data, err := os.Open(filepath.Clean(cfg.File))
+	if err != nil {
+		return fmt.Errorf("loading data: opening input file %q: %w", cfg.File, err)
+	}
+	defer data.Close()
+
+	var plaintext bytes.Buffer
+
+	if _, err := encryptor.Process(data, &plaintext); err != nil {
+		return fmt.Errorf("processing data: %w", err)
+	}
+
+	lines, err := dotenv.Parse(&plaintext)
+	if err != nil {
+		return fmt.Errorf("parsing %q: %w", cfg.File, err)
+	}
+
+	env := os.Environ()
+
+	for _, line := range lines {
+		if line.IsAssignment() {
+			env = append(env, line.Key+"="+dotenv.Unquote(line.Value))
+		}
+	}
+
+	command := exec.Command(cfg.Command[0], cfg.Command[1:]...) //nolint:gosec // running the given command is the point
+	command.Stdin, command.Stdout, command.Stderr = os.Stdin, os.Stdout, os.Stderr
+	command.Env = env
+
+	signals := make(chan os.Signal, 1)
+	signal.Notify(signals, forwardedSignals...)
+
+	defer func() {
+		signal.Stop(signals)
+		close(signals)
+	}()
+
+	if err := command.Start(); err != nil {
+		return fmt.Errorf("starting %q: %w", cfg.Command[0], err)
+	}
+
+	go func() {
+		for sig := range signals {
+			_ = command.Process.Signal(sig)
+		}
+	}()
+
+	if err := command.Wait(); err != nil {
+		if exitErr := newExitError(cfg.Command[0], err); exitErr != nil {
+			return exitErr
+		}
+
+		return fmt.Errorf("running %q: %w", cfg.Command[0], err)
+	}
+
+	return nil
+}
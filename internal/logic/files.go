@@ -0,0 +1,328 @@
+package logic
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"sync"
+
+	"github.com/idelchi/gocry/internal/config"
+	"github.com/idelchi/gocry/internal/encrypt"
+	"github.com/idelchi/gogen/pkg/printer"
+)
+
+const (
+	// privatePerm is the permission of files created with content that may be secret.
+	privatePerm = 0o600
+
+	// dirPerm is the permission of created output directories.
+	dirPerm = 0o755
+)
+
+// writeFileAtomic replaces the file at path with data.
+// The data is written to a temporary file in the same directory which is then renamed over the original,
+// so readers never observe a partially written file. The permissions of an existing file are kept,
+// and a new file is created with perm.
+func writeFileAtomic(path string, data []byte, perm os.FileMode) (err error) {
+	if info, err := os.Stat(path); err == nil {
+		perm = info.Mode().Perm()
+	}
+
+	tmp, err := os.CreateTemp(filepath.Dir(path), "."+filepath.Base(path)+".gocry-*")
+	if err != nil {
+		return fmt.Errorf("creating temporary file: %w", err)
+	}
+
+	defer func() {
+		if err != nil {
+			_ = os.Remove(tmp.Name())
+		}
+	}()
+
+	if _, err := tmp.Write(data); err != nil {
+		_ = tmp.Close()
+
+		return fmt.Errorf("writing temporary file: %w", err)
+	}
+
+	if err := tmp.Chmod(perm); err != nil {
+		_ = tmp.Close()
+
+		return fmt.Errorf("setting permissions: %w", err)
+	}
+
+	if err := tmp.Close(); err != nil {
+		return fmt.Errorf("closing temporary file: %w", err)
+	}
+
+	if err := os.Rename(tmp.Name(), path); err != nil {
+		return fmt.Errorf("replacing %q: %w", path, err)
+	}
+
+	return nil
+}
+
+// fileResult holds the outcome of processing a single file.
+type fileResult struct {
+	// target is the path the result was written to
+	target string
+
+	// processed reports whether anything was encrypted or decrypted
+	processed bool
+
+	// skipped reports whether the file was skipped, being encrypted already (or not encrypted, for decryption)
+	skipped bool
+
+	// err is any error encountered
+	err error
+}
+
+// runFiles processes the configured files, globs and directories in parallel and writes the results to files.
+// A summary line is printed for each file.
+func runFiles(cfg *config.Config, encryptor *encrypt.Encryptor) error {
+	files, err := expandPaths(cfg.Files, cfg.Output.Recursive)
+	if err != nil {
+		return err
+	}
+
+	if err := checkTargets(files, cfg.Output, cfg.Operation); err != nil {
+		return err
+	}
+
+	results := make([]fileResult, len(files))
+	work := make(chan int)
+	workers := max(1, min(cfg.Parallel, len(files)))
+
+	// Share the workers between files and the segments within them
+	encryptor.Parallel = max(1, cfg.Parallel/workers)
+
+	var waitGroup sync.WaitGroup
+
+	for range workers {
+		waitGroup.Add(1)
+
+		go func() {
+			defer waitGroup.Done()
+
+			for idx := range work {
+				// Envelopes are bound to the path of the plaintext, which a decrypted file gets without the suffix
+				plain := files[idx]
+				if cfg.Operation == encrypt.Decrypt && cfg.Output.Suffix != "" {
+					plain = strings.TrimSuffix(plain, cfg.Output.Suffix)
+				}
+
+				fileEncryptor := *encryptor
+				fileEncryptor.Path = bindingPath(plain)
+
+				results[idx] = processFile(&fileEncryptor, files[idx], cfg.Output)
+			}
+		}()
+	}
+
+	for idx := range files {
+		work <- idx
+	}
+
+	close(work)
+	waitGroup.Wait()
+
+	var errs []error
+
+	for idx, result := range results {
+		file := files[idx]
+
+		switch {
+		case result.err != nil:
+			errs = append(errs, fmt.Errorf("processing %q: %w", file, result.err))
+		case cfg.Quiet:
+		case result.skipped && cfg.Operation == encrypt.Encrypt:
+			printer.Stderrln("skipped, already encrypted: %q", file)
+		case result.skipped:
+			printer.Stderrln("skipped, not encrypted: %q", file)
+		case !result.processed && result.target == "":
+			printer.Stderrln("unchanged: %q", file)
+		case !result.processed:
+			printer.Stderrln("copied unchanged: %q -> %q", file, result.target)
+		case result.target == file:
+			printer.Stderrln("%sed: %q", cfg.Operation, file)
+		default:
+			printer.Stderrln("%sed: %q -> %q", cfg.Operation, file, result.target)
+		}
+	}
+
+	return errors.Join(errs...)
+}
+
+// processFile processes a single file in memory and writes the result to its output path.
+// Whole files that are already encrypted are skipped when encrypting, and those that are not when decrypting.
+// A file replaced in place is only written if anything changed.
+func processFile(encryptor *encrypt.Encryptor, file string, output config.Output) fileResult {
+	info, err := os.Stat(file)
+	if err != nil {
+		return fileResult{err: err}
+	}
+
+	data, err := os.ReadFile(filepath.Clean(file))
+	if err != nil {
+		return fileResult{err: fmt.Errorf("reading file: %w", err)}
+	}
+
+	if encryptor.Mode == encrypt.File && encrypt.IsEncrypted(data) == (encryptor.Operation == encrypt.Encrypt) {
+		return fileResult{skipped: true}
+	}
+
+	target, err := outputPath(file, output, encryptor.Operation)
+	if err != nil {
+		return fileResult{err: err}
+	}
+
+	var out bytes.Buffer
+
+	processed, err := encryptor.Process(bytes.NewReader(data), &out)
+	if err != nil {
+		return fileResult{err: err}
+	}
+
+	if output.InPlace && !processed {
+		return fileResult{}
+	}
+
+	if err := os.MkdirAll(filepath.Dir(target), dirPerm); err != nil {
+		return fileResult{err: fmt.Errorf("creating output directory: %w", err)}
+	}
+
+	return fileResult{
+		target:    target,
+		processed: processed,
+		err:       writeFileAtomic(target, out.Bytes(), info.Mode().Perm()),
+	}
+}
+
+// outputPath returns the path the result for file is written to.
+// Encrypted files get the suffix appended, decrypted files must carry it and get it removed.
+// Within an output directory, relative paths are kept, while files outside the current directory
+// are written to its top level.
+func outputPath(file string, output config.Output, operation encrypt.Operation) (string, error) {
+	target := file
+
+	switch {
+	case output.Suffix == "":
+	case operation == encrypt.Encrypt:
+		target += output.Suffix
+	case !strings.HasSuffix(target, output.Suffix) || target == output.Suffix:
+		return "", fmt.Errorf("%w: file name does not end with %q", config.ErrUsage, output.Suffix)
+	default:
+		target = strings.TrimSuffix(target, output.Suffix)
+	}
+
+	if output.Dir == "" {
+		return target, nil
+	}
+
+	if !filepath.IsLocal(target) {
+		target = filepath.Base(target)
+	}
+
+	return filepath.Join(output.Dir, target), nil
+}
+
+// checkTargets fails if two files would be written to the same output path,
+// as files outside the current directory are flattened into the output directory.
+// Files without a valid output path are left to fail on their own.
+func checkTargets(files []string, output config.Output, operation encrypt.Operation) error {
+	sources := make(map[string]string, len(files))
+
+	for _, file := range files {
+		target, err := outputPath(file, output, operation)
+		if err != nil {
+			continue
+		}
+
+		target = filepath.Clean(target)
+
+		if source, ok := sources[target]; ok {
+			return fmt.Errorf("%w: %q and %q would both be written to %q", config.ErrUsage, source, file, target)
+		}
+
+		sources[target] = file
+	}
+
+	return nil
+}
+
+// expandPaths resolves the given paths into the files to process.
+// Patterns are expanded as globs, and directories are walked when recursive is set, skipping `.git` directories.
+// Without recursive, directories matched by a glob are skipped, while directories named explicitly are an error.
+func expandPaths(paths []string, recursive bool) ([]string, error) {
+	var files []string
+
+	for _, path := range paths {
+		matches := []string{path}
+		glob := strings.ContainsAny(path, "*?[")
+
+		if glob {
+			var err error
+
+			if matches, err = filepath.Glob(path); err != nil {
+				return nil, fmt.Errorf("%w: invalid pattern %q: %w", config.ErrUsage, path, err)
+			}
+
+			if len(matches) == 0 {
+				return nil, fmt.Errorf("%w: no files match %q", config.ErrUsage, path)
+			}
+		}
+
+		for _, match := range matches {
+			info, err := os.Stat(match)
+			if err != nil {
+				return nil, fmt.Errorf("loading data: %w", err)
+			}
+
+			if !info.IsDir() {
+				files = append(files, match)
+
+				continue
+			}
+
+			if !recursive {
+				if glob {
+					continue
+				}
+
+				return nil, fmt.Errorf("%w: %q is a directory, use --recursive", config.ErrUsage, match)
+			}
+
+			err = filepath.WalkDir(match, func(path string, entry fs.DirEntry, err error) error {
+				switch {
+				case err != nil:
+					return err
+				case entry.IsDir() && entry.Name() == ".git":
+					return filepath.SkipDir
+				case entry.Type().IsRegular():
+					files = append(files, path)
+				}
+
+				return nil
+			})
+			if err != nil {
+				return nil, fmt.Errorf("walking %q: %w", match, err)
+			}
+		}
+	}
+
+	// Drop files named more than once, keeping their first position
+	seen := make(map[string]bool, len(files))
+
+	return slices.DeleteFunc(files, func(file string) bool {
+		file = filepath.Clean(file)
+		duplicate := seen[file]
+		seen[file] = true
+
+		return duplicate
+	}), nil
+}
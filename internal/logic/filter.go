@@ -0,0 +1,268 @@
+package logic
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"maps"
+	"os"
+	"slices"
+	"strings"
+
+	"github.com/idelchi/gocry/internal/config"
+	"github.com/idelchi/gocry/internal/encrypt"
+	"github.com/idelchi/gocry/internal/pktline"
+	"github.com/idelchi/gogen/pkg/printer"
+)
+
+// filterCapabilities are the capabilities of the filter process, offered when git supports them.
+var filterCapabilities = []string{"clean", "smudge", "delay"}
+
+// filterResult is the result of filtering a single file.
+type filterResult struct {
+	// pathname is the path of the file, relative to the top of the work tree
+	pathname string
+
+	// content is the filtered content
+	content []byte
+
+	// err is any error encountered
+	err error
+}
+
+// filterProcess serves the requests of git over the long-running filter process protocol.
+// Smudge requests that git allows to delay are processed in the background, in parallel,
+// and handed over when git asks for them.
+type filterProcess struct {
+	// encryptor is the template for the encryptor of each request
+	encryptor *encrypt.Encryptor
+
+	// reader reads the requests of git
+	reader *pktline.Reader
+
+	// writer writes the responses to git
+	writer *pktline.Writer
+
+	// delay reports whether git agreed to delayed smudging
+	delay bool
+
+	// workers limits the number of files processed in the background at once
+	workers chan struct{}
+
+	// pending is the number of delayed files still being processed
+	pending int
+
+	// completed receives the delayed files once processed
+	completed chan filterResult
+
+	// available holds the delayed files processed but not yet handed over, by path
+	available map[string]filterResult
+}
+
+// FilterProcess runs a long-running filter process for git, serving clean and smudge requests
+// on stdin and stdout until git closes stdin. The key is loaded once for all files.
+func FilterProcess(cfg *config.Config) error {
+	encryptor, err := newEncryptor(cfg)
+	if err != nil {
+		return err
+	}
+
+	return newFilterProcess(encryptor, os.Stdin, os.Stdout).run()
+}
+
+// newFilterProcess creates a filter process reading the requests of git from in and writing the responses to out.
+// Delayed files are processed with as many workers as the encryptor has parallel jobs.
+func newFilterProcess(encryptor *encrypt.Encryptor, in io.Reader, out io.Writer) *filterProcess {
+	return &filterProcess{
+		encryptor: encryptor,
+		reader:    pktline.NewReader(in),
+		writer:    pktline.NewWriter(out),
+		workers:   make(chan struct{}, max(1, encryptor.Parallel)),
+		completed: make(chan filterResult),
+		available: make(map[string]filterResult),
+	}
+}
+
+// run agrees on the protocol with git and serves its requests until git closes the input.
+func (p *filterProcess) run() error {
+	if err := p.handshake(); err != nil {
+		return fmt.Errorf("filter process: %w", err)
+	}
+
+	for {
+		if err := p.serve(); err != nil {
+			if errors.Is(err, io.EOF) {
+				return nil
+			}
+
+			return fmt.Errorf("filter process: %w", err)
+		}
+	}
+}
+
+// handshake agrees on the protocol version and the capabilities with git.
+func (p *filterProcess) handshake() error {
+	welcome, err := p.reader.ReadText()
+	if err != nil {
+		return err
+	}
+
+	if len(welcome) == 0 || welcome[0] != "git-filter-client" || !slices.Contains(welcome, "version=2") {
+		return fmt.Errorf("%w: unexpected welcome %q", pktline.ErrProtocol, welcome)
+	}
+
+	if err := p.writer.WriteText("git-filter-server", "version=2"); err != nil {
+		return err
+	}
+
+	offered, err := p.reader.ReadText()
+	if err != nil {
+		return err
+	}
+
+	var capabilities []string
+
+	for _, capability := range filterCapabilities {
+		if slices.Contains(offered, "capability="+capability) {
+			capabilities = append(capabilities, "capability="+capability)
+			p.delay = p.delay || capability == "delay"
+		}
+	}
+
+	return p.writer.WriteText(capabilities...)
+}
+
+// serve reads and answers a single request.
+func (p *filterProcess) serve() error {
+	lines, err := p.reader.ReadText()
+	if err != nil {
+		return err
+	}
+
+	request := make(map[string]string, len(lines))
+
+	for _, line := range lines {
+		key, value, _ := strings.Cut(line, "=")
+		request[key] = value
+	}
+
+	command, pathname := request["command"], request["pathname"]
+
+	switch command {
+	case "clean", "smudge":
+	case "list_available_blobs":
+		return p.listAvailable()
+	default:
+		return p.writer.WriteText("status=error")
+	}
+
+	content, err := p.reader.ReadContent()
+	if err != nil {
+		return err
+	}
+
+	operation := encrypt.Encrypt
+	if command == "smudge" {
+		operation = encrypt.Decrypt
+	}
+
+	switch result, delayed := p.available[pathname]; {
+	case command == "smudge" && delayed:
+		// A delayed file, handed over after being listed as available
+		delete(p.available, pathname)
+
+		return p.respond(result)
+	case command == "smudge" && p.delay && request["can-delay"] == "1":
+		p.startDelayed(pathname, content)
+
+		return p.writer.WriteText("status=delayed")
+	default:
+		return p.respond(p.filter(pathname, content, operation, p.encryptor.Parallel))
+	}
+}
+
+// filter encrypts or decrypts the content of the file at pathname.
+func (p *filterProcess) filter(pathname string, content []byte, operation encrypt.Operation, parallel int) filterResult {
+	encryptor := *p.encryptor
+	encryptor.Operation = operation
+	encryptor.Path = pathname
+	encryptor.Parallel = parallel
+
+	var out bytes.Buffer
+
+	if _, err := encryptor.Process(bytes.NewReader(content), &out); err != nil {
+		return filterResult{pathname: pathname, err: fmt.Errorf("%sing %q: %w", operation, pathname, err)}
+	}
+
+	return filterResult{pathname: pathname, content: out.Bytes()}
+}
+
+// startDelayed decrypts the content of the file at pathname in the background.
+func (p *filterProcess) startDelayed(pathname string, content []byte) {
+	p.pending++
+
+	go func() {
+		p.workers <- struct{}{}
+		result := p.filter(pathname, content, encrypt.Decrypt, 1)
+		<-p.workers
+
+		p.completed <- result
+	}()
+}
+
+// listAvailable answers which delayed files are available, waiting for one if none is yet.
+// An empty list tells git that no delayed files are left.
+func (p *filterProcess) listAvailable() error {
+	if len(p.available) == 0 && p.pending > 0 {
+		p.collect(<-p.completed)
+	}
+
+	for done := false; !done; {
+		select {
+		case result := <-p.completed:
+			p.collect(result)
+		default:
+			done = true
+		}
+	}
+
+	pathnames := make([]string, 0, len(p.available))
+
+	for _, pathname := range slices.Sorted(maps.Keys(p.available)) {
+		pathnames = append(pathnames, "pathname="+pathname)
+	}
+
+	if err := p.writer.WriteText(pathnames...); err != nil {
+		return err
+	}
+
+	return p.writer.WriteText("status=success")
+}
+
+// collect records a delayed file as available.
+func (p *filterProcess) collect(result filterResult) {
+	p.pending--
+	p.available[result.pathname] = result
+}
+
+// respond sends the result of filtering a file to git. Failures are reported on stderr,
+// as git only reports that the filter failed.
+func (p *filterProcess) respond(result filterResult) error {
+	if result.err != nil {
+		printer.Stderrln("%v", result.err)
+
+		return p.writer.WriteText("status=error")
+	}
+
+	if err := p.writer.WriteText("status=success"); err != nil {
+		return err
+	}
+
+	if err := p.writer.WriteContent(result.content); err != nil {
+		return err
+	}
+
+	// An empty list keeps the status
+	return p.writer.WriteFlush()
+}
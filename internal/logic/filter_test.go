@@ -0,0 +1,163 @@
+package logic
+
+import (
+	"bytes"
+	"crypto/rand"
+	"slices"
+	"testing"
+
+	"github.com/idelchi/gocry/internal/encrypt"
+	"github.com/idelchi/gocry/internal/pktline"
+)
+
+// testKey returns a random key of the length deterministic encryption uses.
+func testKey(t *testing.T) []byte {
+	t.Helper()
+
+	key := make([]byte, 64) //nolint:mnd // the length of a deterministic key
+	if _, err := rand.Read(key); err != nil {
+		t.Fatal(err)
+	}
+
+	return key
+}
+
+// testKeyring returns a keyring holding key.
+func testKeyring(t *testing.T, key []byte) *encrypt.Keyring {
+	t.Helper()
+
+	keyring, err := encrypt.NewKeyring([]encrypt.Key{{Name: "test", Material: key}}, "")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return keyring
+}
+
+func TestFilterProcess(t *testing.T) {
+	t.Parallel()
+
+	encryptor := &encrypt.Encryptor{
+		Keyring:       testKeyring(t, testKey(t)),
+		Mode:          encrypt.File,
+		Deterministic: true,
+		BindPath:      true,
+		Parallel:      2, //nolint:mnd // delayed files are processed in parallel
+	}
+	plaintext := []byte("PASSWORD=hunter2\n")
+
+	// The blobs git stores for a file, which are bound to its path
+	blob := func(pathname string) []byte {
+		t.Helper()
+
+		clean := *encryptor
+		clean.Operation = encrypt.Encrypt
+		clean.Path = pathname
+
+		var encrypted bytes.Buffer
+
+		if _, err := clean.Process(bytes.NewReader(plaintext), &encrypted); err != nil {
+			t.Fatal(err)
+		}
+
+		return encrypted.Bytes()
+	}
+
+	encrypted, delayed := blob("secrets.env"), blob("delayed/secrets.env")
+
+	// The conversation of git, as it would write it to the filter process
+	var script bytes.Buffer
+
+	git := pktline.NewWriter(&script)
+
+	request := func(content []byte, lines ...string) {
+		t.Helper()
+
+		if err := git.WriteText(lines...); err != nil {
+			t.Fatal(err)
+		}
+
+		if content != nil {
+			if err := git.WriteContent(content); err != nil {
+				t.Fatal(err)
+			}
+		}
+	}
+
+	request(nil, "git-filter-client", "version=2")
+	request(nil, "capability=clean", "capability=smudge", "capability=delay", "capability=unknown")
+	request(plaintext, "command=clean", "pathname=secrets.env")
+	request(encrypted, "command=smudge", "pathname=secrets.env")
+	request(delayed, "command=smudge", "pathname=delayed/secrets.env", "can-delay=1")
+	request(nil, "command=list_available_blobs")
+	request([]byte{}, "command=smudge", "pathname=delayed/secrets.env")
+	request(nil, "command=list_available_blobs")
+	request(encrypted, "command=smudge", "pathname=moved.env")
+	request(nil, "command=unknown")
+
+	var out bytes.Buffer
+
+	if err := newFilterProcess(encryptor, &script, &out).run(); err != nil {
+		t.Fatal(err)
+	}
+
+	// The responses of the filter process, as git would read them
+	responses := pktline.NewReader(&out)
+
+	expect := func(want ...string) {
+		t.Helper()
+
+		got, err := responses.ReadText()
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if !slices.Equal(got, want) {
+			t.Fatalf("got %q, want %q", got, want)
+		}
+	}
+
+	content := func(want []byte) {
+		t.Helper()
+
+		got, err := responses.ReadContent()
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if !bytes.Equal(got, want) {
+			t.Fatalf("got content %q, want %q", got, want)
+		}
+
+		// The status is kept
+		expect()
+	}
+
+	expect("git-filter-server", "version=2")
+	expect("capability=clean", "capability=smudge", "capability=delay")
+
+	// The file is encrypted bound to its path, and decrypted again
+	expect("status=success")
+	content(encrypted)
+	expect("status=success")
+	content(plaintext)
+
+	// A smudge git allows to delay is handed over once listed as available, under the path it was requested for
+	expect("status=delayed")
+	expect("pathname=delayed/secrets.env")
+	expect("status=success")
+	expect("status=success")
+	content(plaintext)
+
+	// Nothing is left to hand over
+	expect()
+	expect("status=success")
+
+	// The envelope is bound to another path, and unknown commands are refused
+	expect("status=error")
+	expect("status=error")
+
+	if out.Len() != 0 {
+		t.Fatalf("unexpected trailing output: %q", out.Bytes())
+	}
+}
@@ -0,0 +1,345 @@
+package logic
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"regexp"
+	"slices"
+	"strings"
+
+	"github.com/idelchi/gocry/internal/config"
+	"github.com/idelchi/gocry/internal/encrypt"
+	"github.com/idelchi/gogen/pkg/printer"
+)
+
+// errGit indicates that running git failed.
+var errGit = errors.New("git failed")
+
+const (
+	// gitAttributesFile is the attributes file at the top of the work tree.
+	gitAttributesFile = ".gitattributes"
+
+	// gitDriverPrefix prefixes the names of the filters and drivers gocry configures, one per mode.
+	gitDriverPrefix = "encrypt:"
+
+	// gitAttributesPerm is the permission of a created attributes file, which is committed like any other.
+	gitAttributesPerm = 0o644
+)
+
+// gitModes are the modes a filter and diff driver are configured for.
+var gitModes = []encrypt.Mode{encrypt.Line, encrypt.File}
+
+// gitSetting is a single entry of the git configuration.
+type gitSetting struct {
+	// key is the name of the entry, e.g. `filter.encrypt:line.clean`
+	key string
+
+	// value is the value of the entry
+	value string
+}
+
+// gitSections returns the configuration sections gocry manages.
+func gitSections() []string {
+	var sections []string
+
+	for _, mode := range gitModes {
+		sections = append(sections, "filter."+gitDriverPrefix+string(mode), "diff."+gitDriverPrefix+string(mode))
+	}
+
+	return append(sections, "merge."+gitDriverPrefix+string(encrypt.Line))
+}
+
+// gitSettings returns the configuration entries for the filters and drivers, using the configured key.
+// Filters are marked as required, so that git fails instead of committing plaintext when gocry fails.
+// git uses the long-running filter process where it can, and runs clean and smudge per file otherwise.
+// Only line-mode files get a merge driver, as whole files have no lines to merge.
+func gitSettings(cfg *config.Config) ([]gitSetting, error) {
+	flag, path := "--key-file", cfg.Key.File
+	if cfg.Key.Keyring != "" {
+		flag, path = "--keyring", cfg.Key.Keyring
+	}
+
+	path, err := filepath.Abs(path)
+	if err != nil {
+		return nil, fmt.Errorf("resolving key path: %w", err)
+	}
+
+	if _, err := os.Stat(path); err != nil {
+		return nil, fmt.Errorf("%w: key: %w", config.ErrUsage, err)
+	}
+
+	command := fmt.Sprintf("%s --quiet %s %s", shellQuote(cfg.Git.Program), flag, shellQuote(path))
+
+	encryptArgs, processArgs, mergeArgs := "encrypt", "git filter-process", "git merge"
+	if !cfg.Deterministic {
+		encryptArgs += " --deterministic=false"
+		processArgs += " --deterministic=false"
+		mergeArgs += " --deterministic=false"
+	}
+
+	if cfg.BindPath {
+		encryptArgs += " --bind-path"
+		processArgs += " --bind-path"
+	}
+
+	var settings []gitSetting
+
+	for _, mode := range gitModes {
+		base := fmt.Sprintf("%s --mode %s", command, mode)
+		filter := "filter." + gitDriverPrefix + string(mode)
+
+		// git expands placeholders such as %f in clean, smudge and merge driver commands,
+		// but runs the process filter and textconv commands as they are
+		expanded := strings.ReplaceAll(base, "%", "%%")
+
+		settings = append(settings,
+			gitSetting{filter + ".clean", expanded + " " + encryptArgs + " %f"},
+			gitSetting{filter + ".smudge", expanded + " decrypt %f"},
+			gitSetting{filter + ".process", base + " " + processArgs},
+			gitSetting{filter + ".required", "true"},
+			gitSetting{"diff." + gitDriverPrefix + string(mode) + ".textconv", base + " textconv"},
+		)
+
+		if mode == encrypt.Line {
+			driver := "merge." + gitDriverPrefix + string(mode)
+
+			settings = append(settings,
+				gitSetting{driver + ".name", "gocry merge of decrypted lines"},
+				gitSetting{driver + ".driver", expanded + " " + mergeArgs + " %O %A %B %P"},
+			)
+		}
+	}
+
+	return settings, nil
+}
+
+// GitInstall configures the filters and drivers in the local configuration of the current repository
+// and adds the configured patterns to its `.gitattributes`. The configuration is read back afterwards,
+// to make sure that it is in effect and that the filters are required.
+func GitInstall(cfg *config.Config) error {
+	root, err := gitRoot()
+	if err != nil {
+		return err
+	}
+
+	settings, err := gitSettings(cfg)
+	if err != nil {
+		return err
+	}
+
+	for _, setting := range settings {
+		if _, err := git("config", "--local", setting.key, setting.value); err != nil {
+			return err
+		}
+	}
+
+	for _, setting := range settings {
+		value, err := git("config", "--get", setting.key)
+		if err != nil || value != setting.value {
+			return fmt.Errorf("%w: %s is %q instead of %q, check for overriding configuration",
+				errGit, setting.key, value, setting.value)
+		}
+	}
+
+	var attributes []string
+
+	for _, mode := range gitModes {
+		patterns := cfg.Git.LinePatterns
+		if mode == encrypt.File {
+			patterns = cfg.Git.FilePatterns
+		}
+
+		format := "%s filter=%s%s diff=%[2]s%[3]s"
+		if mode == encrypt.Line {
+			format += " merge=%[2]s%[3]s"
+		}
+
+		for _, pattern := range patterns {
+			attributes = append(attributes, fmt.Sprintf(format, pattern, gitDriverPrefix, mode))
+		}
+	}
+
+	added, err := addGitAttributes(filepath.Join(root, gitAttributesFile), attributes)
+	if err != nil {
+		return err
+	}
+
+	if !cfg.Quiet {
+		printer.Stderrln("configured filters, diff drivers and merge driver in: %q", root)
+
+		if added > 0 {
+			printer.Stderrln("added %d pattern(s) to: %q", added, gitAttributesFile)
+		}
+	}
+
+	return nil
+}
+
+// GitUninstall removes the filters and drivers from the local configuration of the current repository,
+// together with the `.gitattributes` lines using them. An attributes file left empty is removed.
+func GitUninstall(cfg *config.Config) error {
+	root, err := gitRoot()
+	if err != nil {
+		return err
+	}
+
+	for _, section := range gitSections() {
+		// Sections that do not exist are skipped, as --remove-section fails on them
+		if _, err := git("config", "--local", "--get-regexp", "^"+regexp.QuoteMeta(section)+`\.`); err != nil {
+			continue
+		}
+
+		if _, err := git("config", "--local", "--remove-section", section); err != nil {
+			return err
+		}
+	}
+
+	removed, err := removeGitAttributes(filepath.Join(root, gitAttributesFile))
+	if err != nil {
+		return err
+	}
+
+	if !cfg.Quiet {
+		printer.Stderrln("removed filters, diff drivers and merge driver from: %q", root)
+
+		if removed > 0 {
+			printer.Stderrln("removed %d pattern(s) from: %q", removed, gitAttributesFile)
+		}
+	}
+
+	return nil
+}
+
+// addGitAttributes appends the lines not present yet to the attributes file at path and returns how many were added.
+func addGitAttributes(path string, lines []string) (int, error) {
+	data, err := os.ReadFile(filepath.Clean(path))
+	if err != nil && !errors.Is(err, fs.ErrNotExist) {
+		return 0, fmt.Errorf("reading %q: %w", path, err)
+	}
+
+	existing := strings.Split(string(data), "\n")
+
+	var out bytes.Buffer
+
+	out.Write(data)
+
+	if len(data) > 0 && !bytes.HasSuffix(data, []byte("\n")) {
+		out.WriteString("\n")
+	}
+
+	added := 0
+
+	for _, line := range lines {
+		if slices.Contains(existing, line) {
+			continue
+		}
+
+		existing = append(existing, line)
+		out.WriteString(line + "\n")
+		added++
+	}
+
+	if added == 0 {
+		return 0, nil
+	}
+
+	return added, writeFileAtomic(path, out.Bytes(), gitAttributesPerm)
+}
+
+// removeGitAttributes removes the lines using a gocry filter or driver from the attributes file at path
+// and returns how many were removed.
+func removeGitAttributes(path string) (int, error) {
+	data, err := os.ReadFile(filepath.Clean(path))
+	if errors.Is(err, fs.ErrNotExist) {
+		return 0, nil
+	}
+
+	if err != nil {
+		return 0, fmt.Errorf("reading %q: %w", path, err)
+	}
+
+	lines := strings.SplitAfter(string(data), "\n")
+	kept := slices.DeleteFunc(slices.Clone(lines), isGocryAttribute)
+	removed := len(lines) - len(kept)
+
+	switch {
+	case removed == 0:
+		return 0, nil
+	case strings.TrimSpace(strings.Join(kept, "")) == "":
+		if err := os.Remove(path); err != nil {
+			return 0, fmt.Errorf("removing %q: %w", path, err)
+		}
+
+		return removed, nil
+	default:
+		return removed, writeFileAtomic(path, []byte(strings.Join(kept, "")), gitAttributesPerm)
+	}
+}
+
+// isGocryAttribute reports whether an attributes line uses a gocry filter or driver.
+func isGocryAttribute(line string) bool {
+	fields := strings.Fields(line)
+	if len(fields) < 2 || strings.HasPrefix(fields[0], "#") { //nolint:mnd // a pattern and an attribute
+		return false
+	}
+
+	return slices.ContainsFunc(fields[1:], func(attribute string) bool {
+		_, value, found := strings.Cut(attribute, "=")
+
+		return found && strings.HasPrefix(value, gitDriverPrefix)
+	})
+}
+
+// gitRoot returns the top-level directory of the current work tree.
+func gitRoot() (string, error) {
+	root, err := git("rev-parse", "--show-toplevel")
+	if err != nil {
+		return "", fmt.Errorf("%w: not inside a git work tree", config.ErrUsage)
+	}
+
+	return root, nil
+}
+
+// git runs git with args and returns its trimmed output.
+func git(args ...string) (string, error) {
+	output, err := gitInput("", "", args...)
+
+	return strings.TrimSpace(string(output)), err
+}
+
+// gitInput runs git with args in dir, or in the current directory if empty, feeding it input,
+// and returns its output as it is.
+// The error carries the output of git, but not the *exec.ExitError, so gocry does not exit with its exit code.
+func gitInput(dir, input string, args ...string) ([]byte, error) {
+	var stdout, stderr bytes.Buffer
+
+	command := exec.Command("git", args...)
+	command.Dir = dir
+	command.Stdin = strings.NewReader(input)
+	command.Stdout, command.Stderr = &stdout, &stderr
+
+	if err := command.Run(); err != nil {
+		message := strings.TrimSpace(stderr.String())
+		if message == "" {
+			message = err.Error()
+		}
+
+		return nil, fmt.Errorf("%w: git %s: %s", errGit, args[0], message)
+	}
+
+	return stdout.Bytes(), nil
+}
+
+// shellQuote quotes s for the shell git runs filter commands with, unless it is safe as it is.
+func shellQuote(s string) string {
+	if s != "" && strings.Trim(s, "abcdefghijklmnopqrstuvwxyzABCDEFGHIJKLMNOPQRSTUVWXYZ0123456789-_./:=@+,") == "" {
+		return s
+	}
+
+	return "'" + strings.ReplaceAll(s, "'", `'\''`) + "'"
+}
@@ -0,0 +1,191 @@
+package logic
+
+import (
+	"bufio"
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"maps"
+	"slices"
+	"strconv"
+	"strings"
+
+	"github.com/idelchi/gocry/internal/config"
+	"github.com/idelchi/gocry/internal/encrypt"
+	"github.com/idelchi/gogen/pkg/printer"
+)
+
+// errUnencrypted indicates files that should be encrypted but are not.
+var errUnencrypted = errors.New("unencrypted content")
+
+// GitCheck verifies that the files of the current repository using a gocry filter are encrypted in the index:
+// the staged files by default, or all tracked files. Whole files must hold a valid envelope,
+// and line-mode files must not carry lines still marked for encryption.
+// Every offending file is reported, and an error is returned if there are any.
+// git runs from the top of the work tree, where the paths it lists are relative to,
+// so that a check from a subdirectory covers the whole repository.
+func GitCheck(cfg *config.Config) error {
+	root, err := gitRoot()
+	if err != nil {
+		return err
+	}
+
+	args := []string{"diff", "--cached", "--name-only", "--diff-filter=ACMR", "-z"}
+	if cfg.Git.All {
+		args = []string{"ls-files", "--cached", "-z"}
+	}
+
+	listed, err := gitInput(root, "", args...)
+	if err != nil {
+		return err
+	}
+
+	var files []string
+
+	for file := range strings.SplitSeq(string(listed), "\x00") {
+		if file != "" {
+			files = append(files, file)
+		}
+	}
+
+	filters, err := gitFilters(root, files)
+	if err != nil {
+		return err
+	}
+
+	var (
+		checked  []string
+		problems []string
+	)
+
+	for _, file := range files {
+		if mode := filters[file]; mode == encrypt.File || mode == encrypt.Line {
+			checked = append(checked, file)
+		}
+	}
+
+	blobs, err := indexBlobs(root, checked)
+	if err != nil {
+		return err
+	}
+
+	encryptor := &encrypt.Encryptor{Directives: cfg.Directives}
+
+	for idx, file := range checked {
+		blob := blobs[idx]
+
+		switch filters[file] {
+		case encrypt.File:
+			if !encrypt.IsEncrypted(blob) {
+				problems = append(problems, fmt.Sprintf("%s: not encrypted (filter=%s%s)", file, gitDriverPrefix, encrypt.File))
+			}
+		case encrypt.Line:
+			for _, line := range encryptor.PendingLines(blob) {
+				problems = append(problems, fmt.Sprintf("%s:%d: still marked for encryption", file, line))
+			}
+		}
+	}
+
+	// A missing filter explains the problems, but is no problem by itself
+	for _, mode := range gitModes {
+		if cfg.Quiet || !slices.Contains(slices.Collect(maps.Values(filters)), mode) {
+			continue
+		}
+
+		if _, err := git("config", "--get", "filter."+gitDriverPrefix+string(mode)+".clean"); err != nil {
+			printer.Stderrln("warning: filter %s%s is not configured, run `gocry git install`", gitDriverPrefix, mode)
+		}
+	}
+
+	for _, problem := range problems {
+		printer.Stderrln("%s", problem)
+	}
+
+	if len(problems) > 0 {
+		return fmt.Errorf("%w: %d problem(s) found, check the filter configuration and re-add the files",
+			errUnencrypted, len(problems))
+	}
+
+	if !cfg.Quiet {
+		printer.Stderrln("checked %d file(s) using gocry filters", len(checked))
+	}
+
+	return nil
+}
+
+// gitFilters returns the gocry mode of the filter attribute of each file, as read from the `.gitattributes` in the index.
+// Files are given relative to root, and files without a gocry filter are left out.
+func gitFilters(root string, files []string) (map[string]encrypt.Mode, error) {
+	filters := make(map[string]encrypt.Mode)
+
+	if len(files) == 0 {
+		return filters, nil
+	}
+
+	output, err := gitInput(root, strings.Join(files, "\x00"), "check-attr", "--cached", "--stdin", "-z", "filter")
+	if err != nil {
+		return nil, err
+	}
+
+	// The output is made of triplets: path, attribute, value
+	fields := strings.Split(string(output), "\x00")
+
+	for idx := 0; idx+2 < len(fields); idx += 3 {
+		if mode, ok := strings.CutPrefix(fields[idx+2], gitDriverPrefix); ok {
+			filters[fields[idx]] = encrypt.Mode(mode)
+		}
+	}
+
+	return filters, nil
+}
+
+// indexBlobs returns the content staged in the index for each file, given relative to root.
+func indexBlobs(root string, files []string) ([][]byte, error) {
+	if len(files) == 0 {
+		return nil, nil
+	}
+
+	var input strings.Builder
+
+	for _, file := range files {
+		input.WriteString(":" + file + "\n")
+	}
+
+	output, err := gitInput(root, input.String(), "cat-file", "--batch")
+	if err != nil {
+		return nil, err
+	}
+
+	reader := bufio.NewReader(bytes.NewReader(output))
+	blobs := make([][]byte, len(files))
+
+	for idx, file := range files {
+		header, err := reader.ReadString('\n')
+		if err != nil {
+			return nil, fmt.Errorf("%w: reading blob of %q: %w", errGit, file, err)
+		}
+
+		// The header is `<oid> <type> <size>`, or `<object> missing`
+		fields := strings.Fields(header)
+		if len(fields) != 3 { //nolint:mnd // oid, type and size
+			return nil, fmt.Errorf("%w: %q is not in the index", errGit, file)
+		}
+
+		size, err := strconv.Atoi(fields[2])
+		if err != nil {
+			return nil, fmt.Errorf("%w: invalid blob header %q", errGit, header)
+		}
+
+		// The content is followed by a newline
+		blobs[idx] = make([]byte, size+1)
+
+		if _, err := io.ReadFull(reader, blobs[idx]); err != nil {
+			return nil, fmt.Errorf("%w: reading blob of %q: %w", errGit, file, err)
+		}
+
+		blobs[idx] = blobs[idx][:size]
+	}
+
+	return blobs, nil
+}
@@ -0,0 +1,184 @@
+package logic
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+
+	"github.com/idelchi/gocry/internal/config"
+	"github.com/idelchi/gocry/internal/encrypt"
+	"github.com/idelchi/gocry/internal/merge"
+	"github.com/idelchi/gogen/pkg/printer"
+)
+
+// errConflict indicates a merge that left conflicts to resolve.
+var errConflict = errors.New("merge conflict")
+
+// GitMerge merges line-mode files for git's merge.<driver>.driver.
+// The common ancestor, our version and their version are given as cfg.Files, and cfg.File is the path
+// the merged file has in the repository. All three versions are decrypted, merged line by line,
+// and the result is encrypted again into our version, which is where git expects it.
+// Conflicts are marked around the decrypted lines, widened so that a marker never separates a line
+// from the encrypt directive or block it belongs to; the marked content is encrypted like any other.
+// The result is bound to cfg.File if our or their version holds units bound to their path,
+// and keeps the line endings and final newline of the versions.
+// An error is returned if conflicts are left, and our version is not touched if anything fails.
+func GitMerge(cfg *config.Config) error {
+	encryptor, err := newEncryptor(cfg)
+	if err != nil {
+		return err
+	}
+
+	// Check that the result can be encrypted before merging
+	if encryptor.Keyring != nil {
+		if err := validateKeyring(encryptor.Keyring, encrypt.Encrypt, encryptor.Deterministic); err != nil {
+			return err
+		}
+	}
+
+	versions := make([][]string, len(cfg.Files))
+	layouts := make([]lineLayout, len(cfg.Files))
+	bound := false
+
+	for idx, file := range cfg.Files {
+		data, err := os.ReadFile(filepath.Clean(file))
+		if err != nil {
+			return fmt.Errorf("reading %q: %w", file, err)
+		}
+
+		layouts[idx] = layoutOf(data)
+
+		// Our and their version carry the binding of the merged units, the common ancestor is only read
+		if idx > 0 && !bound {
+			infos, err := encryptor.InspectLines(bytes.NewReader(data))
+			if err != nil {
+				return fmt.Errorf("inspecting %q: %w", file, err)
+			}
+
+			bound = slices.ContainsFunc(infos, func(info encrypt.LineInfo) bool { return info.Bound })
+		}
+
+		var plaintext bytes.Buffer
+
+		if _, err := encryptor.Process(bytes.NewReader(data), &plaintext); err != nil {
+			return fmt.Errorf("decrypting %q: %w", file, err)
+		}
+
+		versions[idx] = splitLines(plaintext.String())
+	}
+
+	merged, conflicts := merge.Merge(versions[0], versions[1], versions[2], merge.Options{
+		Ours:   "ours",
+		Theirs: "theirs",
+		Glue:   mergeGlue(cfg.Directives),
+	})
+
+	encryptor.Operation = encrypt.Encrypt
+	encryptor.BindPath = bound
+
+	var out bytes.Buffer
+
+	if len(merged) > 0 {
+		if _, err := encryptor.Process(strings.NewReader(strings.Join(merged, "\n")+"\n"), &out); err != nil {
+			return fmt.Errorf("encrypting the merge of %q: %w", cfg.File, err)
+		}
+	}
+
+	result := mergeLayout(layouts[0], layouts[1], layouts[2]).apply(out.Bytes())
+
+	if err := writeFileAtomic(cfg.Files[1], result, privatePerm); err != nil {
+		return err
+	}
+
+	if conflicts > 0 {
+		return fmt.Errorf("%w: %d conflict(s) in %q", errConflict, conflicts, cfg.File)
+	}
+
+	if !cfg.Quiet {
+		printer.Stderrln("merged: %q", cfg.File)
+	}
+
+	return nil
+}
+
+// lineLayout is how the lines of a file end, which line-mode processing normalizes to a newline after every line.
+type lineLayout struct {
+	// eol is the line ending, "\n" or "\r\n"
+	eol string
+
+	// final reports whether the last line ends with a line ending
+	final bool
+}
+
+// layoutOf returns the layout of data. Empty data ends like any other.
+func layoutOf(data []byte) lineLayout {
+	layout := lineLayout{eol: "\n", final: len(data) == 0 || bytes.HasSuffix(data, []byte("\n"))}
+
+	if bytes.Contains(data, []byte("\r\n")) {
+		layout.eol = "\r\n"
+	}
+
+	return layout
+}
+
+// mergeLayout merges the layouts of the common ancestor, our version and their version:
+// each property is ours, unless only their version changed it.
+func mergeLayout(base, ours, theirs lineLayout) lineLayout {
+	merged := ours
+
+	if ours.eol == base.eol {
+		merged.eol = theirs.eol
+	}
+
+	if ours.final == base.final {
+		merged.final = theirs.final
+	}
+
+	return merged
+}
+
+// apply converts text with a newline after every line to the layout.
+func (l lineLayout) apply(text []byte) []byte {
+	if !l.final {
+		text = bytes.TrimSuffix(text, []byte("\n"))
+	}
+
+	return bytes.ReplaceAll(text, []byte("\n"), []byte(l.eol))
+}
+
+// mergeGlue returns which lines stick to the lines following them: a standalone encrypt directive
+// and the lines of a block up to its end directive, as they are encrypted together with what follows.
+func mergeGlue(directives encrypt.Directives) func([]string) []bool {
+	return func(lines []string) []bool {
+		glued := make([]bool, len(lines))
+		inBlock := false
+
+		for idx, line := range lines {
+			trimmed := strings.TrimSpace(line)
+
+			switch {
+			case directives.Begin != "" && trimmed == directives.Begin:
+				inBlock = true
+			case directives.End != "" && trimmed == directives.End:
+				inBlock = false
+			}
+
+			glued[idx] = inBlock || (directives.Encrypt != "" && trimmed == directives.Encrypt)
+		}
+
+		return glued
+	}
+}
+
+// splitLines splits text with a newline after every line into its lines, without their line endings.
+func splitLines(text string) []string {
+	if text == "" {
+		return nil
+	}
+
+	return strings.Split(strings.TrimSuffix(text, "\n"), "\n")
+}
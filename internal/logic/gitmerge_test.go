@@ -0,0 +1,186 @@
+package logic
+
+import (
+	"bytes"
+	"encoding/hex"
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/idelchi/gocry/internal/config"
+	"github.com/idelchi/gocry/internal/encrypt"
+)
+
+// testDirectives are the default directives.
+//
+//nolint:gochecknoglobals // these globals are acceptable
+var testDirectives = encrypt.Directives{
+	Encrypt: "### DIRECTIVE: ENCRYPT",
+	Decrypt: "### DIRECTIVE: DECRYPT",
+	Begin:   "### DIRECTIVE: BEGIN ENCRYPT",
+	End:     "### DIRECTIVE: END ENCRYPT",
+}
+
+func TestGitMerge(t *testing.T) {
+	t.Parallel()
+
+	base := "name: app\npassword: hunter-base ### DIRECTIVE: ENCRYPT\ntoken: token-base ### DIRECTIVE: ENCRYPT\nport: 1\n"
+	ours := "name: app-ours\npassword: hunter-base ### DIRECTIVE: ENCRYPT\ntoken: token-base ### DIRECTIVE: ENCRYPT\nport: 1\n"
+
+	tests := []struct {
+		name      string
+		theirs    string
+		layout    lineLayout
+		bound     bool
+		want      string
+		conflicts bool
+	}{
+		{
+			name:   "clean",
+			theirs: strings.Replace(base, "token-base", "token-theirs", 1),
+			layout: lineLayout{eol: "\n", final: true},
+			want:   strings.Replace(ours, "token-base", "token-theirs", 1),
+		},
+		{
+			name:   "bound, crlf and no final newline",
+			theirs: strings.Replace(base, "token-base", "token-theirs", 1),
+			layout: lineLayout{eol: "\r\n"},
+			bound:  true,
+			want:   strings.Replace(ours, "token-base", "token-theirs", 1),
+		},
+		{
+			name:   "conflict",
+			theirs: strings.Replace(base, "name: app", "name: app-theirs", 1),
+			layout: lineLayout{eol: "\n", final: true},
+			bound:  true,
+			want: "<<<<<<< ours\nname: app-ours\n=======\nname: app-theirs\n>>>>>>> theirs\n" +
+				"password: hunter-base ### DIRECTIVE: ENCRYPT\ntoken: token-base ### DIRECTIVE: ENCRYPT\nport: 1\n",
+			conflicts: true,
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			t.Parallel()
+
+			key := testKey(t)
+			dir := t.TempDir()
+			file := filepath.Join(dir, "app.yaml")
+
+			encryptor := encrypt.Encryptor{
+				Keyring:       testKeyring(t, key),
+				Mode:          encrypt.Line,
+				Directives:    testDirectives,
+				Parallel:      1,
+				Deterministic: true,
+				Path:          bindingPath(file),
+				BindPath:      test.bound,
+			}
+
+			process := func(operation encrypt.Operation, input []byte) ([]byte, error) {
+				encryptor := encryptor
+				encryptor.Operation = operation
+
+				var out bytes.Buffer
+
+				_, err := encryptor.Process(bytes.NewReader(input), &out)
+
+				return out.Bytes(), err
+			}
+
+			cfg := &config.Config{
+				Parallel:      1,
+				Mode:          encrypt.Line,
+				Operation:     encrypt.Decrypt,
+				Directives:    testDirectives,
+				Deterministic: true,
+				Quiet:         true,
+				Key:           config.Key{String: hex.EncodeToString(key)},
+				File:          file,
+			}
+
+			for idx, plaintext := range []string{base, ours, test.theirs} {
+				encrypted, err := process(encrypt.Encrypt, []byte(plaintext))
+				if err != nil {
+					t.Fatal(err)
+				}
+
+				version := filepath.Join(dir, []string{"base", "ours", "theirs"}[idx])
+				if err := os.WriteFile(version, test.layout.apply(encrypted), privatePerm); err != nil {
+					t.Fatal(err)
+				}
+
+				cfg.Files = append(cfg.Files, version)
+			}
+
+			switch err := GitMerge(cfg); {
+			case test.conflicts && !errors.Is(err, errConflict):
+				t.Fatalf("got %v, want %v", err, errConflict)
+			case !test.conflicts && err != nil:
+				t.Fatal(err)
+			}
+
+			merged, err := os.ReadFile(cfg.Files[1])
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			if bytes.Contains(merged, []byte("hunter-base")) || bytes.Contains(merged, []byte("token-")) {
+				t.Fatalf("merge holds plaintext: %s", merged)
+			}
+
+			// The line endings and the final newline are kept
+			if layout := layoutOf(merged); layout != test.layout {
+				t.Fatalf("got layout %+v, want %+v", layout, test.layout)
+			}
+
+			// The units keep their binding
+			infos, err := encryptor.InspectLines(bytes.NewReader(merged))
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			for _, info := range infos {
+				if info.Bound != test.bound {
+					t.Fatalf("line %d: got bound %t, want %t", info.Line, info.Bound, test.bound)
+				}
+			}
+
+			decrypted, err := process(encrypt.Decrypt, merged)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			if string(decrypted) != test.want {
+				t.Fatalf("got %q, want %q", decrypted, test.want)
+			}
+		})
+	}
+}
+
+func TestMergeLayout(t *testing.T) {
+	t.Parallel()
+
+	lf, crlf := lineLayout{eol: "\n", final: true}, lineLayout{eol: "\r\n", final: true}
+	open := lineLayout{eol: "\n"}
+
+	tests := []struct {
+		name               string
+		base, ours, theirs lineLayout
+		want               lineLayout
+	}{
+		{name: "unchanged", base: lf, ours: lf, theirs: lf, want: lf},
+		{name: "changed by us", base: lf, ours: crlf, theirs: lf, want: crlf},
+		{name: "changed by them", base: lf, ours: lf, theirs: crlf, want: crlf},
+		{name: "changed by both", base: crlf, ours: open, theirs: lf, want: open},
+		{name: "final newline removed by them", base: lf, ours: lf, theirs: open, want: open},
+	}
+
+	for _, test := range tests {
+		if got := mergeLayout(test.base, test.ours, test.theirs); got != test.want {
+			t.Fatalf("%s: got %+v, want %+v", test.name, got, test.want)
+		}
+	}
+}
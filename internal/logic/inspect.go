@@ -0,0 +1,188 @@
+package logic
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"maps"
+	"slices"
+	"strings"
+
+	"github.com/idelchi/gocry/internal/config"
+	"github.com/idelchi/gocry/internal/encrypt"
+	"github.com/idelchi/gogen/pkg/printer"
+)
+
+// inspection is the result of inspecting a file, as printed with --json.
+type inspection struct {
+	// File is the inspected file
+	File string `json:"file"`
+
+	// Kind is "file" for a whole-file envelope, "line" for text holding envelopes and "none" otherwise
+	Kind string `json:"kind"`
+
+	// Envelope describes the whole-file envelope
+	Envelope *encrypt.EnvelopeInfo `json:"envelope,omitempty"`
+
+	// Lines describe the envelopes found in text
+	Lines []encrypt.LineInfo `json:"lines,omitempty"`
+
+	// Directives is the number of envelopes on lines carrying the decryption directive
+	Directives int `json:"directives"`
+
+	// Modes counts the envelopes found in text by mode
+	Modes map[string]int `json:"modes,omitempty"`
+}
+
+// Inspect describes the envelopes in the configured file without decrypting them, so no key is needed.
+// Data starting with the envelope magic is inspected as a whole-file envelope,
+// anything else is scanned for the base64-encoded envelopes of line and structured modes.
+func Inspect(cfg *config.Config) error {
+	file, err := loadData(cfg.File)
+	if err != nil {
+		return fmt.Errorf("loading data: %w", err)
+	}
+	defer file.Close()
+
+	data, err := io.ReadAll(file)
+	if err != nil {
+		return fmt.Errorf("loading data: reading %q: %w", cfg.File, err)
+	}
+
+	result := inspection{File: cfg.File, Kind: "none"}
+
+	if encrypt.HasEnvelopeMagic(data) {
+		info, err := encrypt.Inspect(data)
+		if err != nil {
+			return fmt.Errorf("inspecting %q: %w", cfg.File, err)
+		}
+
+		result.Kind, result.Envelope = "file", &info
+	} else {
+		encryptor := &encrypt.Encryptor{Directives: cfg.Directives}
+
+		if result.Lines, err = encryptor.InspectLines(bytes.NewReader(data)); err != nil {
+			return fmt.Errorf("inspecting %q: %w", cfg.File, err)
+		}
+
+		if len(result.Lines) > 0 {
+			result.Kind, result.Modes = "line", make(map[string]int)
+		}
+
+		for _, line := range result.Lines {
+			result.Modes[line.Mode]++
+
+			if line.Directive {
+				result.Directives++
+			}
+		}
+	}
+
+	if cfg.Inspect.JSON {
+		out, err := json.MarshalIndent(result, "", "  ")
+		if err != nil {
+			return fmt.Errorf("encoding result: %w", err)
+		}
+
+		printer.Stdoutln("%s", out)
+
+		return nil
+	}
+
+	printInspection(result)
+
+	return nil
+}
+
+// printInspection prints the result of inspecting a file for humans.
+func printInspection(result inspection) {
+	switch result.Kind {
+	case "file":
+		printer.Stdoutln("%s: gocry envelope", result.File)
+		printEnvelope(*result.Envelope, "  ")
+	case "line":
+		modes := make([]string, 0, len(result.Modes))
+
+		for _, mode := range slices.Sorted(maps.Keys(result.Modes)) {
+			modes = append(modes, fmt.Sprintf("%d %s", result.Modes[mode], mode))
+		}
+
+		printer.Stdoutln(
+			"%s: %d envelope(s) (%s), %d on directive lines",
+			result.File, len(result.Lines), strings.Join(modes, ", "), result.Directives,
+		)
+
+		for _, line := range result.Lines {
+			directive := ""
+			if line.Directive {
+				directive = ", directive"
+			}
+
+			printer.Stdoutln("  line %d: %s, %d bytes%s", line.Line, describeEnvelope(line.EnvelopeInfo), line.Size, directive)
+		}
+	default:
+		printer.Stdoutln("%s: no gocry envelopes", result.File)
+	}
+}
+
+// printEnvelope prints the fields of an envelope, and of the envelope it wraps, one per line.
+func printEnvelope(info encrypt.EnvelopeInfo, indent string) {
+	field := func(name string, value any) {
+		printer.Stdoutln("%s%-15s %v", indent, name+":", value)
+	}
+
+	field("version", info.Version)
+	field("mode", info.Mode)
+	field("algorithm", info.Algorithm)
+
+	if info.KeyID != "" {
+		field("key id", info.KeyID)
+	}
+
+	field("bound", info.Bound)
+	field("size", info.Size)
+
+	if info.TagSize > 0 {
+		field("tag size", info.TagSize)
+	}
+
+	if info.Segments > 0 {
+		field("segment size", info.SegmentSize)
+		field("segments", info.Segments)
+	}
+
+	if info.KDF != "" {
+		field("kdf", info.KDF)
+	}
+
+	if info.Recipients > 0 {
+		field("recipients", info.Recipients)
+	}
+
+	field("plaintext size", info.PlaintextSize)
+
+	if info.Inner != nil {
+		printer.Stdoutln("%sinner envelope:", indent)
+		printEnvelope(*info.Inner, indent+"  ")
+	}
+}
+
+// describeEnvelope summarizes an envelope on a single line.
+func describeEnvelope(info encrypt.EnvelopeInfo) string {
+	description := fmt.Sprintf("v%d %s", info.Version, info.Mode)
+
+	if info.Inner != nil {
+		description += fmt.Sprintf(" (%s)", info.Inner.Mode)
+	}
+
+	if info.KeyID != "" {
+		description += ", key " + info.KeyID
+	}
+
+	if info.Bound {
+		description += ", bound"
+	}
+
+	return description
+}
@@ -0,0 +1,126 @@
+package logic
+
+import (
+	"crypto/ecdh"
+	"crypto/rand"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+
+	"github.com/idelchi/gocry/internal/config"
+	"github.com/idelchi/gocry/internal/encrypt"
+	"github.com/idelchi/gogen/pkg/key"
+	"github.com/idelchi/gogen/pkg/printer"
+)
+
+// Keygen generates a key of the configured type, writes it to the configured file
+// and prints its fingerprint (and, for X25519 identities, the public key).
+func Keygen(cfg *config.Config) error {
+	var (
+		material    []byte
+		fingerprint []byte
+		recipient   string
+	)
+
+	switch {
+	case cfg.Keygen.X25519:
+		if cfg.Keygen.Entry != "" {
+			return fmt.Errorf("%w: keyring entries hold symmetric keys, not X25519 identities", config.ErrUsage)
+		}
+
+		identity, err := ecdh.X25519().GenerateKey(rand.Reader)
+		if err != nil {
+			return fmt.Errorf("generating identity: %w", err)
+		}
+
+		material = identity.Bytes()
+		fingerprint = identity.PublicKey().Bytes()
+		recipient = hex.EncodeToString(identity.PublicKey().Bytes())
+	default:
+		length := normalKeyLen
+		if cfg.Deterministic {
+			length = deterministicKeyLen
+		}
+
+		generated, err := key.New(length)
+		if err != nil {
+			return fmt.Errorf("generating key: %w", err)
+		}
+
+		material = generated
+		fingerprint = generated
+	}
+
+	if err := writeKeyFile(cfg.File, hex.EncodeToString(material)+"\n", cfg.Keygen.Force); err != nil {
+		return err
+	}
+
+	id, err := encrypt.Fingerprint(fingerprint)
+	if err != nil {
+		return fmt.Errorf("computing fingerprint: %w", err)
+	}
+
+	printer.Stdoutln("fingerprint: %s", id)
+
+	if recipient != "" {
+		printer.Stdoutln("public key: %s", recipient)
+	}
+
+	if cfg.Keygen.Entry != "" {
+		printer.Stdoutln("  - name: %s\n    key: %s", cfg.Keygen.Entry, hex.EncodeToString(material))
+	}
+
+	if !cfg.Quiet {
+		printer.Stderrln("wrote %d-byte key to: %q", len(material), cfg.File)
+	}
+
+	return nil
+}
+
+// writeKeyFile writes a key to path, readable by the owner only.
+// An existing file is only replaced if force is set.
+func writeKeyFile(path, content string, force bool) error {
+	const perm = 0o600
+
+	flags := os.O_WRONLY | os.O_CREATE | os.O_EXCL
+	if force {
+		flags = os.O_WRONLY | os.O_CREATE | os.O_TRUNC
+	}
+
+	file, err := os.OpenFile(filepath.Clean(path), flags, perm)
+	if errors.Is(err, fs.ErrExist) {
+		return fmt.Errorf("%w: key file %q already exists, use --force to overwrite", config.ErrUsage, path)
+	}
+
+	if err != nil {
+		return fmt.Errorf("creating key file: %w", err)
+	}
+
+	if err := file.Chmod(perm); err != nil {
+		_ = file.Close()
+
+		return fmt.Errorf("setting key file permissions: %w", err)
+	}
+
+	if _, err := file.WriteString(content); err != nil {
+		_ = file.Close()
+
+		return fmt.Errorf("writing key file: %w", err)
+	}
+
+	// A key that did not reach the disk must not be reported as written
+	if err := file.Sync(); err != nil {
+		_ = file.Close()
+
+		return fmt.Errorf("writing key file: %w", err)
+	}
+
+	if err := file.Close(); err != nil {
+		return fmt.Errorf("writing key file: %w", err)
+	}
+
+	return nil
+}
@@ -0,0 +1,251 @@
+package logic
+
+import (
+	"crypto/ecdh"
+	"fmt"
+	"os"
+	"path/filepath"
+
+	"gopkg.in/yaml.v3"
+
+	"github.com/idelchi/gocry/internal/config"
+	"github.com/idelchi/gocry/internal/encrypt"
+	"github.com/idelchi/gogen/pkg/key"
+)
+
+const (
+	deterministicKeyLen = 64
+	normalKeyLen        = 32
+)
+
+// keyringFile is the on-disk layout of a keyring file.
+//
+//	primary: current
+//	keys:
+//	  - name: previous
+//	    key: <hex>
+//	  - name: current
+//	    key: <hex>
+type keyringFile struct {
+	// Primary is the name of the key used for encryption
+	Primary string `yaml:"primary"`
+
+	// Keys lists the named keys in the keyring
+	Keys []struct {
+		// Name identifies the key
+		Name string `yaml:"name"`
+
+		// Key is the hexadecimal key string
+		Key string `yaml:"key"`
+	} `yaml:"keys"`
+}
+
+// credentials holds whatever the key configuration resolved to.
+type credentials struct {
+	// keyring holds symmetric keys
+	keyring *encrypt.Keyring
+
+	// passphrase derives symmetric keys
+	passphrase *encrypt.Passphrase
+
+	// recipients are the public keys to encrypt for
+	recipients *encrypt.Recipients
+
+	// identities are the private keys to decrypt with
+	identities []*ecdh.PrivateKey
+}
+
+// apply sets the credentials on the encryptor.
+func (c credentials) apply(encryptor *encrypt.Encryptor) {
+	encryptor.Keyring = c.keyring
+	encryptor.Passphrase = c.passphrase
+	encryptor.Recipients = c.recipients
+	encryptor.Identities = c.identities
+}
+
+// loadCredentials resolves the key configuration into a validated keyring,
+// a passphrase, a set of recipients or a set of identities.
+func loadCredentials(cfg config.Key, operation encrypt.Operation, deterministic bool) (credentials, error) {
+	switch {
+	case cfg.Passphrase != "" || cfg.Prompt:
+		passphrase, err := loadPassphrase(cfg, operation == encrypt.Encrypt)
+
+		return credentials{passphrase: passphrase}, err
+	case len(cfg.Recipients) > 0:
+		if operation != encrypt.Encrypt {
+			return credentials{}, fmt.Errorf("%w: --recipient is only used for encryption, use --identity", config.ErrUsage)
+		}
+
+		if deterministic {
+			return credentials{}, fmt.Errorf("%w: --recipient requires --deterministic=false", config.ErrUsage)
+		}
+
+		recipients, err := loadRecipients(cfg.Recipients)
+
+		return credentials{recipients: recipients}, err
+	case cfg.Identity != "":
+		if operation == encrypt.Encrypt {
+			return credentials{}, fmt.Errorf("%w: --identity is only used for decryption, use --recipient", config.ErrUsage)
+		}
+
+		data, err := os.ReadFile(filepath.Clean(cfg.Identity))
+		if err != nil {
+			return credentials{}, fmt.Errorf("reading identity file: %w", err)
+		}
+
+		identities, err := encrypt.ParseIdentities(data)
+		if err != nil {
+			return credentials{}, fmt.Errorf("identity file %q: %w", cfg.Identity, err)
+		}
+
+		return credentials{identities: identities}, nil
+	}
+
+	keyring, err := loadKeyring(cfg)
+	if err != nil {
+		return credentials{}, err
+	}
+
+	// Ensure keys meet requirements depending on operation and mode
+	if err := validateKeyring(keyring, operation, deterministic); err != nil {
+		return credentials{}, err
+	}
+
+	return credentials{keyring: keyring}, nil
+}
+
+// loadRecipients parses hex-encoded X25519 public keys into a recipient set.
+func loadRecipients(keys []string) (*encrypt.Recipients, error) {
+	publicKeys := make([]*ecdh.PublicKey, 0, len(keys))
+
+	for _, k := range keys {
+		publicKey, err := encrypt.ParseRecipient(k)
+		if err != nil {
+			return nil, fmt.Errorf("%w: %w", config.ErrUsage, err)
+		}
+
+		publicKeys = append(publicKeys, publicKey)
+	}
+
+	return encrypt.NewRecipients(publicKeys) //nolint:wrapcheck // error does not need wrapping
+}
+
+// loadPassphrase returns the configured passphrase, prompting for it on the terminal if requested.
+// When confirm is set, the prompt asks for the passphrase twice.
+func loadPassphrase(cfg config.Key, confirm bool) (*encrypt.Passphrase, error) {
+	secret := []byte(cfg.Passphrase)
+
+	if cfg.Prompt {
+		var err error
+
+		secret, err = promptPassphrase(confirm)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	passphrase, err := encrypt.NewPassphrase(secret, cfg.KDF)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %w", config.ErrUsage, err)
+	}
+
+	return passphrase, nil
+}
+
+// loadKeyring builds a keyring from either a hex string, a key file or a keyring file.
+func loadKeyring(cfg config.Key) (*encrypt.Keyring, error) {
+	switch {
+	case cfg.String != "":
+		material, err := key.FromHex(cfg.String)
+		if err != nil {
+			return nil, fmt.Errorf("reading key: %w", err)
+		}
+
+		return encrypt.NewKeyring([]encrypt.Key{{Name: "--key", Material: material}}, "")
+	case cfg.File != "":
+		data, err := os.ReadFile(filepath.Clean(cfg.File))
+		if err != nil {
+			return nil, fmt.Errorf("reading key file: %w", err)
+		}
+
+		material, err := key.FromHex(string(data))
+		if err != nil {
+			return nil, fmt.Errorf("reading key: %w", err)
+		}
+
+		return encrypt.NewKeyring([]encrypt.Key{{Name: cfg.File, Material: material}}, "")
+	case cfg.Keyring != "":
+		return readKeyringFile(cfg.Keyring)
+	}
+
+	return nil, fmt.Errorf("%w: missing key", config.ErrUsage)
+}
+
+// readKeyringFile parses a keyring file into a keyring.
+func readKeyringFile(path string) (*encrypt.Keyring, error) {
+	data, err := os.ReadFile(filepath.Clean(path))
+	if err != nil {
+		return nil, fmt.Errorf("reading keyring file: %w", err)
+	}
+
+	var file keyringFile
+	if err := yaml.Unmarshal(data, &file); err != nil {
+		return nil, fmt.Errorf("parsing keyring file %q: %w", path, err)
+	}
+
+	keys := make([]encrypt.Key, 0, len(file.Keys))
+	names := make(map[string]bool, len(file.Keys))
+
+	for _, entry := range file.Keys {
+		if entry.Name == "" {
+			return nil, fmt.Errorf("%w: keyring %q: key without name", config.ErrUsage, path)
+		}
+
+		if names[entry.Name] {
+			return nil, fmt.Errorf("%w: keyring %q: duplicate key %q", config.ErrUsage, path, entry.Name)
+		}
+
+		names[entry.Name] = true
+
+		material, err := key.FromHex(entry.Key)
+		if err != nil {
+			return nil, fmt.Errorf("keyring %q: reading key %q: %w", path, entry.Name, err)
+		}
+
+		keys = append(keys, encrypt.Key{Name: entry.Name, Material: material})
+	}
+
+	keyring, err := encrypt.NewKeyring(keys, file.Primary)
+	if err != nil {
+		return nil, fmt.Errorf("keyring %q: %w", path, err)
+	}
+
+	return keyring, nil
+}
+
+// validateKeyring ensures the keys meet the requirements of the operation and mode.
+// Encryption only uses the primary key, decryption may use any of them.
+func validateKeyring(keyring *encrypt.Keyring, operation encrypt.Operation, deterministic bool) error {
+	switch operation {
+	case encrypt.Encrypt:
+		primary := keyring.Primary()
+
+		if deterministic {
+			if len(primary.Material) != deterministicKeyLen {
+				return fmt.Errorf("%w: deterministic mode requires 64-byte key (128 hex chars)", config.ErrUsage)
+			}
+		} else {
+			if len(primary.Material) != normalKeyLen {
+				return fmt.Errorf("%w: randomized mode requires 32-byte key (64 hex chars)", config.ErrUsage)
+			}
+		}
+	case encrypt.Decrypt:
+		for _, k := range keyring.Keys() {
+			if len(k.Material) != deterministicKeyLen && len(k.Material) != normalKeyLen {
+				return fmt.Errorf("%w: decrypt requires 32- or 64-byte key (64 or 128 hex chars)", config.ErrUsage)
+			}
+		}
+	}
+
+	return nil
+}
@@ -11,67 +11,22 @@ import (
 	"github.com/idelchi/go-next-tag/pkg/stdin"
 	"github.com/idelchi/gocry/internal/config"
 	"github.com/idelchi/gocry/internal/encrypt"
-	"github.com/idelchi/gogen/pkg/key"
 	"github.com/idelchi/gogen/pkg/printer"
 )
 
 // Run executes the main encryption/decryption logic based on the provided configuration.
-// It handles key loading, input data loading, and processes the data according to the
+// It handles keyring loading, input data loading, and processes the data according to the
 // specified mode and operation.
 //
 //nolint:gocognit // function complexity is acceptable
 func Run(cfg *config.Config) error {
-	var (
-		encryptionKey []byte
-		err           error
-	)
-
-	// Load encryption key either from hex string or file
-	switch {
-	case cfg.Key.String != "":
-		encryptionKey, err = key.FromHex(cfg.Key.String)
-	case cfg.Key.File != "":
-		encryptionKey, err = os.ReadFile(cfg.Key.File)
-		if err != nil {
-			return fmt.Errorf("reading key file: %w", err)
-		}
-
-		encryptionKey, err = key.FromHex(string(encryptionKey))
-	}
-
+	encryptor, err := newEncryptor(cfg)
 	if err != nil {
-		return fmt.Errorf("reading key: %w", err)
-	}
-
-	const (
-		deterministicKeyLen = 64
-		normalKeyLen        = 32
-	)
-
-	// Ensure key meets requirements depending on operation and mode
-	switch cfg.Operation {
-	case encrypt.Encrypt:
-		if cfg.Deterministic {
-			if len(encryptionKey) != deterministicKeyLen {
-				return fmt.Errorf("%w: deterministic mode requires 64-byte key (128 hex chars)", config.ErrUsage)
-			}
-		} else {
-			if len(encryptionKey) != normalKeyLen {
-				return fmt.Errorf("%w: randomized mode requires 32-byte key (64 hex chars)", config.ErrUsage)
-			}
-		}
-	case encrypt.Decrypt:
-		if len(encryptionKey) != deterministicKeyLen && len(encryptionKey) != normalKeyLen {
-			return fmt.Errorf("%w: decrypt requires 32- or 64-byte key (64 or 128 hex chars)", config.ErrUsage)
-		}
+		return err
 	}
 
-	if cfg.Experiments {
-		cfg.Parallel = 1
-
-		if !cfg.Quiet {
-			printer.Stderrln("Experimental features enabled: parallel processing disabled")
-		}
+	if cfg.Output.ToFiles() {
+		return runFiles(cfg, encryptor)
 	}
 
 	// Load input data from stdin or file
@@ -81,16 +36,6 @@ func Run(cfg *config.Config) error {
 	}
 	defer data.Close()
 
-	// Initialize encryptor with configuration
-	encryptor := &encrypt.Encryptor{
-		Key:           encryptionKey,
-		Operation:     cfg.Operation,
-		Mode:          cfg.Mode,
-		Directives:    cfg.Directives,
-		Parallel:      cfg.Parallel,
-		Deterministic: cfg.Deterministic,
-	}
-
 	// Process data and handle any errors
 	processed, err := encryptor.Process(data, os.Stdout)
 	if err != nil {
@@ -106,11 +51,55 @@ func Run(cfg *config.Config) error {
 		if cfg.Mode == "line" && processed {
 			printer.Stderrln("%sed lines in: %q", cfg.Operation, cfg.File)
 		}
+
+		if cfg.Mode != "file" && cfg.Mode != "line" && processed {
+			printer.Stderrln("%sed values in: %q", cfg.Operation, cfg.File)
+		}
 	}
 
 	return nil
 }
 
+// newEncryptor loads the credentials and creates an encryptor for the configured file, mode and operation.
+func newEncryptor(cfg *config.Config) (*encrypt.Encryptor, error) {
+	credentials, err := loadCredentials(cfg.Key, cfg.Operation, cfg.Deterministic)
+	if err != nil {
+		return nil, err
+	}
+
+	if cfg.Experiments {
+		cfg.Parallel = 1
+
+		if !cfg.Quiet {
+			printer.Stderrln("Experimental features enabled: parallel processing disabled")
+		}
+	}
+
+	selector, err := encrypt.NewSelector(cfg.Select, cfg.KeyRegex)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %w", config.ErrUsage, err)
+	}
+
+	// Initialize encryptor with configuration
+	encryptor := &encrypt.Encryptor{
+		Operation:     cfg.Operation,
+		Mode:          cfg.Mode,
+		Directives:    cfg.Directives,
+		Parallel:      cfg.Parallel,
+		Deterministic: cfg.Deterministic,
+		Chunked:       cfg.Chunked,
+		Path:          bindingPath(cfg.File),
+		BindPath:      cfg.BindPath,
+		Values:        cfg.Values,
+		Selector:      selector,
+		Plaintext:     cfg.Plaintext,
+	}
+
+	credentials.apply(encryptor)
+
+	return encryptor, nil
+}
+
 // loadData returns a file handle for the input data.
 func loadData(file string) (*os.File, error) {
 	if stdin.IsPiped() {
@@ -124,3 +113,51 @@ func loadData(file string) (*os.File, error) {
 
 	return data, nil
 }
+
+// bindingPath returns the path envelopes of file are bound to: slash-separated and relative to the top of the
+// git work tree holding the file, or to the current directory outside of one. The binding then neither depends
+// on how the path was typed nor on the directory gocry runs in, and matches the `%f` git hands to filters.
+func bindingPath(file string) string {
+	if file == "" {
+		return ""
+	}
+
+	fallback := filepath.ToSlash(filepath.Clean(file))
+
+	abs, err := filepath.Abs(file)
+	if err != nil {
+		return fallback
+	}
+
+	base, err := os.Getwd()
+	if err != nil {
+		return fallback
+	}
+
+	if root := workTreeRoot(filepath.Dir(abs)); root != "" {
+		base = root
+	}
+
+	rel, err := filepath.Rel(base, abs)
+	if err != nil {
+		return fallback
+	}
+
+	return filepath.ToSlash(rel)
+}
+
+// workTreeRoot returns the closest directory from dir upwards holding a `.git` entry, or "" if there is none.
+func workTreeRoot(dir string) string {
+	for {
+		if _, err := os.Lstat(filepath.Join(dir, ".git")); err == nil {
+			return dir
+		}
+
+		parent := filepath.Dir(dir)
+		if parent == dir {
+			return ""
+		}
+
+		dir = parent
+	}
+}
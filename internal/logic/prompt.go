@@ -0,0 +1,58 @@
+package logic
+
+import (
+	"bytes"
+	"fmt"
+	"os"
+	"runtime"
+
+	"golang.org/x/term"
+
+	"github.com/idelchi/gocry/internal/config"
+)
+
+// promptPassphrase reads a passphrase from the terminal without echoing it.
+// The terminal is opened directly, since stdin usually carries the data to process.
+// When confirm is set, the passphrase has to be entered twice.
+func promptPassphrase(confirm bool) ([]byte, error) {
+	name := "/dev/tty"
+	if runtime.GOOS == "windows" {
+		name = "CONIN$"
+	}
+
+	tty, err := os.Open(name)
+	if err != nil {
+		return nil, fmt.Errorf("%w: opening terminal for passphrase prompt: %w", config.ErrUsage, err)
+	}
+	defer tty.Close()
+
+	read := func(prompt string) ([]byte, error) {
+		fmt.Fprint(os.Stderr, prompt)
+		defer fmt.Fprintln(os.Stderr)
+
+		secret, err := term.ReadPassword(int(tty.Fd())) //nolint:gosec // file descriptors fit into int
+		if err != nil {
+			return nil, fmt.Errorf("reading passphrase: %w", err)
+		}
+
+		return secret, nil
+	}
+
+	secret, err := read("Passphrase: ")
+	if err != nil {
+		return nil, err
+	}
+
+	if confirm {
+		again, err := read("Confirm passphrase: ")
+		if err != nil {
+			return nil, err
+		}
+
+		if !bytes.Equal(secret, again) {
+			return nil, fmt.Errorf("%w: passphrases do not match", config.ErrUsage)
+		}
+	}
+
+	return secret, nil
+}
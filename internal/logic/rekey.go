@@ -0,0 +1,116 @@
+package logic
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sync"
+
+	"github.com/idelchi/gocry/internal/config"
+	"github.com/idelchi/gocry/internal/encrypt"
+	"github.com/idelchi/gogen/pkg/printer"
+)
+
+// rekeyResult holds the outcome of rekeying a single file.
+type rekeyResult struct {
+	// count is the number of re-encrypted envelopes
+	count int
+
+	// err is any error encountered
+	err error
+}
+
+// Rekey re-encrypts the configured files in place under a new key.
+// The configured key (or keyring) decrypts, the new key encrypts, and files are processed in parallel.
+// With a dry run, the files that would change are listed instead of written.
+func Rekey(cfg *config.Config) error {
+	credentials, err := loadCredentials(cfg.Key, encrypt.Decrypt, false)
+	if err != nil {
+		return err
+	}
+
+	newKeyring, err := loadKeyring(config.Key{String: cfg.Rekey.Key, File: cfg.Rekey.File})
+	if err != nil {
+		return fmt.Errorf("loading new key: %w", err)
+	}
+
+	if err := validateKeyring(newKeyring, encrypt.Decrypt, false); err != nil {
+		return fmt.Errorf("validating new key: %w", err)
+	}
+
+	results := make([]rekeyResult, len(cfg.Files))
+	work := make(chan int)
+
+	var waitGroup sync.WaitGroup
+
+	for range max(1, min(cfg.Parallel, len(cfg.Files))) {
+		waitGroup.Add(1)
+
+		go func() {
+			defer waitGroup.Done()
+
+			for idx := range work {
+				encryptor := &encrypt.Encryptor{
+					Mode:       cfg.Mode,
+					Directives: cfg.Directives,
+					Path:       bindingPath(cfg.Files[idx]),
+				}
+
+				credentials.apply(encryptor)
+
+				count, err := rekeyFile(encryptor, cfg.Files[idx], newKeyring, cfg.Rekey.DryRun)
+				results[idx] = rekeyResult{count: count, err: err}
+			}
+		}()
+	}
+
+	for idx := range cfg.Files {
+		work <- idx
+	}
+
+	close(work)
+	waitGroup.Wait()
+
+	var errs []error
+
+	for idx, result := range results {
+		file := cfg.Files[idx]
+
+		switch {
+		case result.err != nil:
+			errs = append(errs, fmt.Errorf("rekeying %q: %w", file, result.err))
+		case cfg.Rekey.DryRun && result.count > 0:
+			printer.Stdoutln("%s: %d envelope(s) would be rekeyed", file, result.count)
+		case cfg.Quiet:
+		case result.count > 0:
+			printer.Stderrln("rekeyed %d envelope(s) in: %q", result.count, file)
+		default:
+			printer.Stderrln("unchanged: %q", file)
+		}
+	}
+
+	return errors.Join(errs...)
+}
+
+// rekeyFile rekeys a single file in memory and replaces it unless this is a dry run.
+func rekeyFile(encryptor *encrypt.Encryptor, file string, keyring *encrypt.Keyring, dryRun bool) (int, error) {
+	data, err := os.ReadFile(filepath.Clean(file))
+	if err != nil {
+		return 0, fmt.Errorf("reading file: %w", err)
+	}
+
+	var out bytes.Buffer
+
+	count, err := encryptor.Rekey(bytes.NewReader(data), &out, keyring)
+	if err != nil {
+		return 0, err
+	}
+
+	if count == 0 || dryRun {
+		return count, nil
+	}
+
+	return count, writeFileAtomic(file, out.Bytes(), privatePerm)
+}
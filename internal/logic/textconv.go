@@ -0,0 +1,100 @@
+package logic
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"fmt"
+	"os"
+	"path/filepath"
+
+	"github.com/idelchi/gocry/internal/config"
+	"github.com/idelchi/gocry/internal/encrypt"
+	"github.com/idelchi/gogen/pkg/printer"
+)
+
+// Textconv prints the decrypted content of a file for git's diff.<driver>.textconv.
+// A whole-file envelope is decrypted whatever the mode, other files are decrypted with the configured mode,
+// and content without envelopes is printed as it is. git runs blobs through the smudge filter before handing them
+// over, so envelopes only arrive here where no filter is configured, as for developers without the key.
+// When the file cannot be decrypted, for instance because no key is available, a whole-file envelope
+// is replaced by a placeholder describing it and other content is printed as it is, so that diffs still work.
+// git hands blobs over as temporary files, not under their path in the repository, so envelopes bound to their
+// path always get the placeholder.
+func Textconv(cfg *config.Config) error {
+	data, err := os.ReadFile(filepath.Clean(cfg.File))
+	if err != nil {
+		return fmt.Errorf("loading data: %w", err)
+	}
+
+	converted, reason := textconv(cfg, data)
+	if reason != "" && !cfg.Quiet {
+		printer.Stderrln("not decrypting %q: %s", cfg.File, reason)
+	}
+
+	if _, err := os.Stdout.Write(converted); err != nil {
+		return fmt.Errorf("writing output: %w", err)
+	}
+
+	return nil
+}
+
+// textconv converts data, returning the reason why it could not be decrypted, if any.
+func textconv(cfg *config.Config, data []byte) ([]byte, string) {
+	whole := encrypt.HasEnvelopeMagic(data)
+
+	if !whole && cfg.Mode == encrypt.File {
+		return data, ""
+	}
+
+	encryptor, reason := textconvEncryptor(cfg)
+
+	if encryptor != nil {
+		if whole {
+			encryptor.Mode = encrypt.File
+		}
+
+		var out bytes.Buffer
+
+		_, err := encryptor.Process(bytes.NewReader(data), &out)
+		if err == nil {
+			return out.Bytes(), ""
+		}
+
+		reason = err.Error()
+	}
+
+	if !whole {
+		return data, reason
+	}
+
+	info, err := encrypt.Inspect(data)
+	if err != nil {
+		return fmt.Appendf(nil, "[gocry: invalid envelope: %v]\n", err), reason
+	}
+
+	if info.Bound && encryptor != nil {
+		reason = "the envelope is bound to its path in the repository, which git does not pass to textconv"
+	}
+
+	// The hash of the ciphertext shows whether the content changed, even without a key
+	hash := sha256.Sum256(data)
+
+	return fmt.Appendf(nil, "[gocry: encrypted file, %s, %d bytes of plaintext, sha256 %x]\n",
+		describeEnvelope(info), info.PlaintextSize, hash[:8]), reason
+}
+
+// textconvEncryptor creates the decrypting encryptor, or returns why there is none.
+func textconvEncryptor(cfg *config.Config) (*encrypt.Encryptor, string) {
+	key := cfg.Key
+
+	if key.String == "" && key.File == "" && key.Keyring == "" && key.Passphrase == "" && key.Identity == "" {
+		return nil, "no key available"
+	}
+
+	encryptor, err := newEncryptor(cfg)
+	if err != nil {
+		return nil, err.Error()
+	}
+
+	return encryptor, ""
+}
@@ -0,0 +1,235 @@
+// Package merge implements a three-way merge of lines, in the manner of diff3.
+package merge
+
+import "slices"
+
+// maxTrace bounds the memory spent on finding the differences between two versions.
+// Versions differing more than that are treated as having no lines in common.
+const maxTrace = 1 << 24
+
+// markerSize is the length of conflict markers, as used by git.
+const markerSize = 7
+
+// Options configures a merge.
+type Options struct {
+	// Ours and Theirs label the sides of conflicts
+	Ours, Theirs string
+
+	// Glue, if set, reports for each line of a version whether it must stay together with the line following it.
+	// Conflicts are widened so that such lines are never separated by a conflict marker.
+	Glue func(lines []string) []bool
+}
+
+// Merge merges the changes from base to ours and from base to theirs, returning the merged lines
+// and the number of conflicts. Changes that overlap conflict, unless they are identical;
+// conflicts are marked as git does, with ours before theirs.
+func Merge(base, ours, theirs []string, options Options) ([]string, int) {
+	toOurs := matches(base, ours)
+	toTheirs := matches(base, theirs)
+
+	// stable reports whether a base line is kept unchanged at the given positions of both sides
+	stable := func(o, a, b int) bool {
+		return o < len(base) && toOurs[o] == a && toTheirs[o] == b
+	}
+
+	glueOurs, glueTheirs := glue(ours, options.Glue), glue(theirs, options.Glue)
+
+	// glued reports whether the lines before positions a and b of the sides stick to the lines at them
+	glued := func(a, b int) bool {
+		return (a > 0 && glueOurs[a-1]) || (b > 0 && glueTheirs[b-1])
+	}
+
+	var (
+		merged    []string
+		conflicts int
+
+		// stableRun is the number of stable lines at the end of the merged lines
+		stableRun int
+	)
+
+	for o, a, b := 0, 0, 0; o < len(base) || a < len(ours) || b < len(theirs); {
+		if stable(o, a, b) {
+			merged = append(merged, base[o])
+			o, a, b = o+1, a+1, b+1
+			stableRun++
+
+			continue
+		}
+
+		// Glued stable lines before the change join it
+		startO, startA, startB := o, a, b
+		for stableRun > 0 && glued(startA, startB) {
+			merged = merged[:len(merged)-1]
+			startO, startA, startB = startO-1, startA-1, startB-1
+			stableRun--
+		}
+
+		// The change ends before the next line kept by both sides, unless that line follows a glued one
+		for {
+			for o < len(base) && (toOurs[o] < 0 || toTheirs[o] < 0) {
+				o++
+			}
+
+			if o >= len(base) {
+				a, b = len(ours), len(theirs)
+
+				break
+			}
+
+			a, b = toOurs[o], toTheirs[o]
+
+			// Kept lines following glued ones join the change
+			for o < len(base) && glued(a, b) && toOurs[o] == a && toTheirs[o] == b {
+				o, a, b = o+1, a+1, b+1
+			}
+
+			if !glued(a, b) {
+				break
+			}
+		}
+
+		stableRun = 0
+
+		baseChunk, oursChunk, theirsChunk := base[startO:o], ours[startA:a], theirs[startB:b]
+
+		switch {
+		case slices.Equal(oursChunk, baseChunk) || slices.Equal(oursChunk, theirsChunk):
+			merged = append(merged, theirsChunk...)
+		case slices.Equal(theirsChunk, baseChunk):
+			merged = append(merged, oursChunk...)
+		default:
+			conflicts++
+
+			merged = append(merged, marker('<', options.Ours))
+			merged = append(merged, oursChunk...)
+			merged = append(merged, marker('=', ""))
+			merged = append(merged, theirsChunk...)
+			merged = append(merged, marker('>', options.Theirs))
+		}
+	}
+
+	return merged, conflicts
+}
+
+// glue returns which lines of a version stick to the lines following them.
+func glue(lines []string, fn func([]string) []bool) []bool {
+	if fn == nil {
+		return make([]bool, len(lines))
+	}
+
+	return fn(lines)
+}
+
+// marker returns a conflict marker line with the given label.
+func marker(char rune, label string) string {
+	line := string(slices.Repeat([]rune{char}, markerSize))
+	if label != "" {
+		line += " " + label
+	}
+
+	return line
+}
+
+// matches returns, for each line of a, the index of the matching line of b in a longest common subsequence,
+// or -1 for lines that are not kept. It uses the greedy algorithm of Myers.
+func matches(a, b []string) []int {
+	result := make([]int, len(a))
+	for idx := range result {
+		result[idx] = -1
+	}
+
+	// Common prefixes and suffixes match trivially
+	prefix := 0
+	for prefix < len(a) && prefix < len(b) && a[prefix] == b[prefix] {
+		result[prefix] = prefix
+		prefix++
+	}
+
+	suffix := 0
+	for suffix < len(a)-prefix && suffix < len(b)-prefix && a[len(a)-1-suffix] == b[len(b)-1-suffix] {
+		result[len(a)-1-suffix] = len(b) - 1 - suffix
+		suffix++
+	}
+
+	innerA, innerB := a[prefix:len(a)-suffix], b[prefix:len(b)-suffix]
+
+	for _, pair := range myers(innerA, innerB) {
+		result[prefix+pair[0]] = prefix + pair[1]
+	}
+
+	return result
+}
+
+// myers returns the index pairs of the lines of a and b in a longest common subsequence.
+func myers(a, b []string) [][2]int {
+	n, m := len(a), len(b)
+	if n == 0 || m == 0 {
+		return nil
+	}
+
+	offset := n + m + 1
+	v := make([]int, 2*offset+1)
+
+	var trace [][]int
+
+	for d := 0; d <= n+m; d++ {
+		if (d+1)*len(v) > maxTrace {
+			return nil
+		}
+
+		trace = append(trace, slices.Clone(v))
+
+		for k := -d; k <= d; k += 2 {
+			var x int
+			if k == -d || (k != d && v[offset+k-1] < v[offset+k+1]) {
+				x = v[offset+k+1]
+			} else {
+				x = v[offset+k-1] + 1
+			}
+
+			y := x - k
+			for x < n && y < m && a[x] == b[y] {
+				x, y = x+1, y+1
+			}
+
+			v[offset+k] = x
+
+			if x >= n && y >= m {
+				return backtrack(trace, offset, n, m)
+			}
+		}
+	}
+
+	return nil
+}
+
+// backtrack walks the trace of myers back from the end and collects the diagonal moves, which are the matches.
+func backtrack(trace [][]int, offset, x, y int) [][2]int {
+	var pairs [][2]int
+
+	for d := len(trace) - 1; d >= 0; d-- {
+		v := trace[d]
+		k := x - y
+
+		prevK := k - 1
+		if k == -d || (k != d && v[offset+k-1] < v[offset+k+1]) {
+			prevK = k + 1
+		}
+
+		prevX := v[offset+prevK]
+		prevY := prevX - prevK
+
+		for x > prevX && y > prevY {
+			x, y = x-1, y-1
+			pairs = append(pairs, [2]int{x, y})
+		}
+
+		if d > 0 {
+			x, y = prevX, prevY
+		}
+	}
+
+	slices.Reverse(pairs)
+
+	return pairs
+}
@@ -0,0 +1,183 @@
+package merge
+
+import (
+	"slices"
+	"strings"
+	"testing"
+)
+
+// split returns the lines of text separated by "|".
+func split(text string) []string {
+	if text == "" {
+		return nil
+	}
+
+	return strings.Split(text, "|")
+}
+
+// plusGlue glues lines ending with "+" to the lines following them.
+func plusGlue(lines []string) []bool {
+	glued := make([]bool, len(lines))
+
+	for idx, line := range lines {
+		glued[idx] = strings.HasSuffix(line, "+")
+	}
+
+	return glued
+}
+
+func TestMerge(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		name      string
+		base      string
+		ours      string
+		theirs    string
+		glue      bool
+		want      string
+		conflicts int
+	}{
+		{
+			name: "non-overlapping edits",
+			base: "a|b|c|d|e", ours: "A|b|c|d|e", theirs: "a|b|c|d|E",
+			want: "A|b|c|d|E",
+		},
+		{
+			name: "insertions on both sides",
+			base: "a|b|c", ours: "x|a|b|c", theirs: "a|b|c|y",
+			want: "x|a|b|c|y",
+		},
+		{
+			name: "adjacent edits",
+			base: "a|b|c", ours: "a|B|c", theirs: "a|b|C",
+			want:      "a|<<<<<<< ours|B|c|=======|b|C|>>>>>>> theirs",
+			conflicts: 1,
+		},
+		{
+			name: "overlapping edits",
+			base: "a|b|c|d|e", ours: "a|B|c|d|e", theirs: "a|X|c|d|E",
+			want:      "a|<<<<<<< ours|B|=======|X|>>>>>>> theirs|c|d|E",
+			conflicts: 1,
+		},
+		{
+			name: "identical edits",
+			base: "a|b|c", ours: "a|X|c", theirs: "a|X|c",
+			want: "a|X|c",
+		},
+		{
+			name: "delete against untouched",
+			base: "a|b|c", ours: "a|c", theirs: "a|b|c",
+			want: "a|c",
+		},
+		{
+			name: "delete against edit",
+			base: "a|b|c", ours: "a|c", theirs: "a|B|c",
+			want:      "a|<<<<<<< ours|=======|B|>>>>>>> theirs|c",
+			conflicts: 1,
+		},
+		{
+			name: "all empty",
+		},
+		{
+			name: "empty base",
+			base: "", ours: "x", theirs: "",
+			want: "x",
+		},
+		{
+			name: "empty base with additions on both sides",
+			base: "", ours: "x", theirs: "y",
+			want:      "<<<<<<< ours|x|=======|y|>>>>>>> theirs",
+			conflicts: 1,
+		},
+		{
+			name: "emptied on one side",
+			base: "a|b", ours: "", theirs: "a|b",
+		},
+		{
+			name: "emptied against edit",
+			base: "a|b", ours: "a|B", theirs: "",
+			want:      "<<<<<<< ours|a|B|=======|>>>>>>> theirs",
+			conflicts: 1,
+		},
+		{
+			name: "unglued line before a conflict",
+			base: "a+|b|c", ours: "a+|B|c", theirs: "a+|C|c",
+			want:      "a+|<<<<<<< ours|B|=======|C|>>>>>>> theirs|c",
+			conflicts: 1,
+		},
+		{
+			name: "glued line before a conflict",
+			base: "a+|b|c", ours: "a+|B|c", theirs: "a+|C|c",
+			glue:      true,
+			want:      "<<<<<<< ours|a+|B|=======|a+|C|>>>>>>> theirs|c",
+			conflicts: 1,
+		},
+		{
+			name: "glued line ending a conflict",
+			base: "x+|y|z", ours: "X+|y|z", theirs: "W+|y|z",
+			glue:      true,
+			want:      "<<<<<<< ours|X+|y|=======|W+|y|>>>>>>> theirs|z",
+			conflicts: 1,
+		},
+		{
+			name: "glued block",
+			base: "a|b+|c+|d|e", ours: "a|b+|C+|d|e", theirs: "a|b+|c+|D|e",
+			glue:      true,
+			want:      "a|<<<<<<< ours|b+|C+|d|=======|b+|c+|D|>>>>>>> theirs|e",
+			conflicts: 1,
+		},
+		{
+			name: "glued lines merging cleanly",
+			base: "p+|q|r|s", ours: "p+|Q|r|s", theirs: "p+|q|r|S",
+			glue: true,
+			want: "p+|Q|r|S",
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			t.Parallel()
+
+			options := Options{Ours: "ours", Theirs: "theirs"}
+			if test.glue {
+				options.Glue = plusGlue
+			}
+
+			merged, conflicts := Merge(split(test.base), split(test.ours), split(test.theirs), options)
+
+			if !slices.Equal(merged, split(test.want)) || conflicts != test.conflicts {
+				t.Fatalf("got %q with %d conflict(s), want %q with %d",
+					strings.Join(merged, "|"), conflicts, test.want, test.conflicts)
+			}
+		})
+	}
+}
+
+func TestMatches(t *testing.T) {
+	t.Parallel()
+
+	a, b := split("a|b|c|a|b|b|a"), split("c|b|a|b|a|c")
+
+	result := matches(a, b)
+
+	// The matches form a common subsequence of the length of a longest one
+	last, common := -1, 0
+
+	for idx, match := range result {
+		if match < 0 {
+			continue
+		}
+
+		if match <= last || a[idx] != b[match] {
+			t.Fatalf("invalid matches %v", result)
+		}
+
+		last = match
+		common++
+	}
+
+	if common != 4 { //nolint:mnd // the length of a longest common subsequence
+		t.Fatalf("got %d common lines, want 4: %v", common, result)
+	}
+}
@@ -0,0 +1,182 @@
+// Package pktline reads and writes the pkt-line format git uses to talk to long-running filter processes.
+//
+// A packet is a 4-digit hexadecimal length, counting the length itself, followed by its data.
+// The special packet "0000" is a flush packet, which ends a list of packets.
+package pktline
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+)
+
+// ErrProtocol indicates data that does not follow the pkt-line format or the expected conversation.
+var ErrProtocol = errors.New("pkt-line protocol error")
+
+const (
+	// MaxDataSize is the largest amount of data a single packet carries.
+	MaxDataSize = 65516
+
+	// headerSize is the size of the length prefix of a packet.
+	headerSize = 4
+)
+
+// Reader reads packets.
+type Reader struct {
+	reader *bufio.Reader
+}
+
+// NewReader creates a Reader reading from reader.
+func NewReader(reader io.Reader) *Reader {
+	return &Reader{reader: bufio.NewReader(reader)}
+}
+
+// ReadPacket reads a single packet, reporting a flush packet by returning flush set and no data.
+// io.EOF is returned only when the input ends before a packet starts.
+func (r *Reader) ReadPacket() (data []byte, flush bool, err error) {
+	header := make([]byte, headerSize)
+
+	if _, err := io.ReadFull(r.reader, header); err != nil {
+		if errors.Is(err, io.ErrUnexpectedEOF) {
+			return nil, false, fmt.Errorf("%w: truncated packet length", ErrProtocol)
+		}
+
+		return nil, false, err //nolint:wrapcheck // io.EOF is returned as it is
+	}
+
+	length, err := strconv.ParseUint(string(header), 16, 16)
+	if err != nil {
+		return nil, false, fmt.Errorf("%w: invalid packet length %q", ErrProtocol, header)
+	}
+
+	switch {
+	case length == 0:
+		return nil, true, nil
+	case length <= headerSize || length > MaxDataSize+headerSize:
+		return nil, false, fmt.Errorf("%w: invalid packet length %d", ErrProtocol, length)
+	}
+
+	data = make([]byte, length-headerSize)
+
+	if _, err := io.ReadFull(r.reader, data); err != nil {
+		return nil, false, fmt.Errorf("%w: truncated packet: %w", ErrProtocol, err)
+	}
+
+	return data, false, nil
+}
+
+// ReadText reads text packets up to the next flush packet and returns them without their line endings.
+func (r *Reader) ReadText() ([]string, error) {
+	var lines []string
+
+	for {
+		data, flush, err := r.ReadPacket()
+		if err != nil {
+			if len(lines) > 0 && errors.Is(err, io.EOF) {
+				err = fmt.Errorf("%w: missing flush packet", ErrProtocol)
+			}
+
+			return nil, err
+		}
+
+		if flush {
+			return lines, nil
+		}
+
+		lines = append(lines, strings.TrimSuffix(string(data), "\n"))
+	}
+}
+
+// ReadContent reads packets up to the next flush packet and returns their data joined together.
+func (r *Reader) ReadContent() ([]byte, error) {
+	var content []byte
+
+	for {
+		data, flush, err := r.ReadPacket()
+		if errors.Is(err, io.EOF) {
+			return nil, fmt.Errorf("%w: missing flush packet", ErrProtocol)
+		}
+
+		if err != nil {
+			return nil, err
+		}
+
+		if flush {
+			return content, nil
+		}
+
+		content = append(content, data...)
+	}
+}
+
+// Writer writes packets. Packets are buffered until a flush packet is written.
+type Writer struct {
+	writer *bufio.Writer
+}
+
+// NewWriter creates a Writer writing to writer.
+func NewWriter(writer io.Writer) *Writer {
+	return &Writer{writer: bufio.NewWriter(writer)}
+}
+
+// WritePacket writes data as a single packet. Empty data is not written, as it would read as a flush packet.
+func (w *Writer) WritePacket(data []byte) error {
+	switch {
+	case len(data) == 0:
+		return nil
+	case len(data) > MaxDataSize:
+		return fmt.Errorf("%w: packet of %d bytes exceeds %d", ErrProtocol, len(data), MaxDataSize)
+	}
+
+	if _, err := fmt.Fprintf(w.writer, "%04x", len(data)+headerSize); err != nil {
+		return fmt.Errorf("writing packet: %w", err)
+	}
+
+	if _, err := w.writer.Write(data); err != nil {
+		return fmt.Errorf("writing packet: %w", err)
+	}
+
+	return nil
+}
+
+// WriteFlush writes a flush packet and sends everything buffered.
+func (w *Writer) WriteFlush() error {
+	if _, err := w.writer.WriteString("0000"); err != nil {
+		return fmt.Errorf("writing flush packet: %w", err)
+	}
+
+	if err := w.writer.Flush(); err != nil {
+		return fmt.Errorf("writing flush packet: %w", err)
+	}
+
+	return nil
+}
+
+// WriteText writes each line as a text packet, followed by a flush packet.
+func (w *Writer) WriteText(lines ...string) error {
+	for _, line := range lines {
+		if err := w.WritePacket([]byte(line + "\n")); err != nil {
+			return err
+		}
+	}
+
+	return w.WriteFlush()
+}
+
+// WriteContent writes content split into packets, followed by a flush packet.
+func (w *Writer) WriteContent(content []byte) error {
+	for len(content) > 0 {
+		size := min(len(content), MaxDataSize)
+
+		if err := w.WritePacket(content[:size]); err != nil {
+			return err
+		}
+
+		content = content[size:]
+	}
+
+	return w.WriteFlush()
+}
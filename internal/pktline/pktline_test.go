@@ -0,0 +1,171 @@
+package pktline
+
+import (
+	"bytes"
+	"errors"
+	"io"
+	"strings"
+	"testing"
+)
+
+func TestReadPacket(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		name  string
+		input string
+		data  string
+		flush bool
+		err   error
+	}{
+		{name: "data", input: "0009hello", data: "hello"},
+		{name: "flush", input: "0000", flush: true},
+		{name: "uppercase length", input: "000Ahello\n", data: "hello\n"},
+		{name: "end of input", input: "", err: io.EOF},
+		{name: "truncated length", input: "00", err: ErrProtocol},
+		{name: "malformed length", input: "zzzzhello", err: ErrProtocol},
+		{name: "signed length", input: "+009hello", err: ErrProtocol},
+		{name: "empty packet", input: "0004", err: ErrProtocol},
+		{name: "length below header", input: "0003", err: ErrProtocol},
+		{name: "length above maximum", input: "fff1", err: ErrProtocol},
+		{name: "truncated data", input: "0009hel", err: ErrProtocol},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			t.Parallel()
+
+			data, flush, err := NewReader(strings.NewReader(test.input)).ReadPacket()
+
+			switch {
+			case test.err != nil && !errors.Is(err, test.err):
+				t.Fatalf("got %v, want %v", err, test.err)
+			case test.err != nil:
+			case err != nil:
+				t.Fatal(err)
+			case string(data) != test.data || flush != test.flush:
+				t.Fatalf("got %q (flush %t), want %q (flush %t)", data, flush, test.data, test.flush)
+			}
+		})
+	}
+}
+
+func TestWritePacket(t *testing.T) {
+	t.Parallel()
+
+	var out bytes.Buffer
+
+	writer := NewWriter(&out)
+
+	if err := writer.WritePacket(nil); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := writer.WritePacket(bytes.Repeat([]byte("x"), MaxDataSize+1)); !errors.Is(err, ErrProtocol) {
+		t.Fatalf("got %v, want %v", err, ErrProtocol)
+	}
+
+	largest := bytes.Repeat([]byte("x"), MaxDataSize)
+
+	if err := writer.WritePacket(largest); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := writer.WriteFlush(); err != nil {
+		t.Fatal(err)
+	}
+
+	if !bytes.HasPrefix(out.Bytes(), []byte("fff0")) || !bytes.HasSuffix(out.Bytes(), []byte("0000")) {
+		t.Fatalf("unexpected framing of %d bytes", out.Len())
+	}
+
+	data, _, err := NewReader(&out).ReadPacket()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if !bytes.Equal(data, largest) {
+		t.Fatalf("got %d bytes, want %d", len(data), len(largest))
+	}
+}
+
+func TestContent(t *testing.T) {
+	t.Parallel()
+
+	for _, size := range []int{0, 1, MaxDataSize, MaxDataSize + 1, 3*MaxDataSize + 7} {
+		content := bytes.Repeat([]byte("0123456789"), size/10+1)[:size]
+
+		var out bytes.Buffer
+
+		if err := NewWriter(&out).WriteContent(content); err != nil {
+			t.Fatal(err)
+		}
+
+		// Full packets, a partial one and the flush packet
+		if packets := (size+MaxDataSize-1)/MaxDataSize + 1; out.Len() != size+packets*headerSize {
+			t.Fatalf("%d bytes written in %d bytes, want %d packets", size, out.Len(), packets)
+		}
+
+		got, err := NewReader(&out).ReadContent()
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if !bytes.Equal(got, content) {
+			t.Fatalf("got %d bytes back, want %d", len(got), size)
+		}
+	}
+}
+
+func TestText(t *testing.T) {
+	t.Parallel()
+
+	var out bytes.Buffer
+
+	writer := NewWriter(&out)
+
+	if err := writer.WriteText("git-filter-server", "version=2"); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := writer.WriteText(); err != nil {
+		t.Fatal(err)
+	}
+
+	if out.String() != "0016git-filter-server\n000eversion=2\n00000000" {
+		t.Fatalf("unexpected packets: %q", out.String())
+	}
+
+	reader := NewReader(&out)
+
+	lines, err := reader.ReadText()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if strings.Join(lines, ",") != "git-filter-server,version=2" {
+		t.Fatalf("got %q", lines)
+	}
+
+	if lines, err := reader.ReadText(); err != nil || len(lines) != 0 {
+		t.Fatalf("got %q, %v for an empty list", lines, err)
+	}
+
+	if _, err := reader.ReadText(); !errors.Is(err, io.EOF) {
+		t.Fatalf("got %v, want %v", err, io.EOF)
+	}
+}
+
+func TestMissingFlush(t *testing.T) {
+	t.Parallel()
+
+	if _, err := NewReader(strings.NewReader("0009hello")).ReadText(); !errors.Is(err, ErrProtocol) {
+		t.Fatalf("reading text: got %v, want %v", err, ErrProtocol)
+	}
+
+	for _, input := range []string{"", "0009hello"} {
+		if _, err := NewReader(strings.NewReader(input)).ReadContent(); !errors.Is(err, ErrProtocol) {
+			t.Fatalf("reading content %q: got %v, want %v", input, err, ErrProtocol)
+		}
+	}
+}
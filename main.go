@@ -15,9 +15,11 @@
 package main
 
 import (
+	"errors"
 	"fmt"
 	"os"
 
+	"github.com/idelchi/gocry/internal/logic"
 	"github.com/idelchi/gocry/internal/parse"
 )
 
@@ -27,6 +29,12 @@ var version = "unknown - unofficial & generated by unknown"
 // main is the entry point of the application.
 func main() {
 	if err := parse.Execute(version); err != nil {
+		// Commands run by exec exit with their own code
+		var exitErr *logic.ExitError
+		if errors.As(err, &exitErr) {
+			os.Exit(exitErr.Code)
+		}
+
 		fmt.Fprintln(os.Stderr, err)
 
 		os.Exit(1)
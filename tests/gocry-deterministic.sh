@@ -9,13 +9,6 @@ trap 'echo "🚨🚨 Tests failed! 🚨🚨"' ERR
 
 echo "Running deterministic tests..."
 
-if ! command -v gogen &>/dev/null; then
-  echo "gogen not found, installing..."
-  mkdir -p ~/.local/bin
-  export PATH="$HOME/.local/bin:$PATH"
-  curl -sSL https://raw.githubusercontent.com/idelchi/gogen/refs/heads/main/install.sh | sh -s -- -v v0.0.0 -d ~/.local/bin
-fi
-
 echo "Installing gocry..."
 go install -buildvcs=false .
 
@@ -27,7 +20,8 @@ cd "${TMPDIR}"
 
 # Generate encryption key
 echo "Generating encryption key with length 64..."
-export GOCRY_KEY=$(gogen key --length 64)
+gocry --quiet keygen --deterministic key >/dev/null
+export GOCRY_KEY=$(cat key)
 export GOCRY_QUIET=true
 
 echo "Starting test [Deterministic, File mode]..."
@@ -57,6 +51,29 @@ cat test.sh.enc | gocry -m line decrypt test.sh.enc >test.sh.dec
 [[ -f "test.sh.dec" ]] || (echo '❌ test [Line mode]: Decrypted file was not created' && exit 1)
 cmp -s test.sh.dec test.sh || (echo '❌ test [Line mode]: File content changed' && exit 1)
 
+echo "Starting test [Deterministic, Line mode, Block]..."
+# Line mode with a block of lines
+cat >test.yaml <<'EOF'
+name: test
+cert: |
+  ### DIRECTIVE: BEGIN ENCRYPT
+  -----BEGIN CERTIFICATE-----
+  MIIBszCCAVmgAwIBAgIU
+  -----END CERTIFICATE-----
+  ### DIRECTIVE: END ENCRYPT
+EOF
+
+# Test block encryption/decryption, sequentially and in parallel
+for parallel in 1 4; do
+  cat test.yaml | gocry -j ${parallel} -m line encrypt test.yaml >test.yaml.enc
+  [[ $(wc -l <test.yaml.enc) -eq 3 ]] || (echo '❌ test [Line mode, Block]: Block was not encrypted into one line' && exit 1)
+
+  cat test.yaml.enc | gocry -j ${parallel} -m line decrypt test.yaml.enc >test.yaml.dec
+  cmp -s test.yaml.dec test.yaml || (echo '❌ test [Line mode, Block]: File content changed' && exit 1)
+done
+
+rm -f test.yaml test.yaml.enc test.yaml.dec
+
 echo "Starting test [Deterministic, file mode]..."
 # Deterministic encryption
 cat >test.sh <<'EOF'
@@ -85,6 +102,208 @@ cmp -s test.sh.enc2 test.sh.enc3 || (echo '❌ test [Deterministic, line mode]:
 
 rm -f test.sh test.sh.enc1 test.sh.enc2 test.sh.enc3 test.sh.enc test.sh.dec
 
+echo "Starting test [Deterministic, textconv, Bound path]..."
+# textconv decrypts envelopes bound to the path they are read from, and shows a placeholder elsewhere
+cat >secrets.env <<'EOF'
+PASSWORD=hunter2
+EOF
+
+cat secrets.env | gocry encrypt --bind-path secrets.env >secrets.env.enc
+mv secrets.env.enc secrets.env
+
+gocry textconv secrets.env | grep -q 'PASSWORD=hunter2' || (echo '❌ test [textconv, Bound path]: File was not decrypted' && exit 1)
+
+mkdir -p blob && cp secrets.env blob/secrets.env
+gocry textconv blob/secrets.env | grep -q '^\[gocry: encrypted file, .*bound' || (echo '❌ test [textconv, Bound path]: No placeholder for a moved file' && exit 1)
+
+rm -rf secrets.env blob
+
+echo "Starting test [Deterministic, git install]..."
+# install and uninstall edit the filters and .gitattributes of a repository, leaving the lines of the user alone
+# The filters use the installed key instead of the environment
+unset GOCRY_KEY
+mkdir 'keys%f' && cp key 'keys%f/key'
+git init -q repo
+cd repo
+git config user.email "gocry@example.com" && git config user.name "gocry"
+
+printf '*.png binary\n# attributes of the user\n*.env diff=other\n' >attributes.user
+cp attributes.user .gitattributes
+
+gocry -f '../keys%f/key' git install --line-pattern '*.env' --file-pattern '*.key'
+[[ $(grep -c 'encrypt:' .gitattributes) -eq 2 ]] || (echo '❌ test [git install]: Patterns were not added' && exit 1)
+[[ $(head -n 3 .gitattributes) == $(cat attributes.user) ]] || (echo '❌ test [git install]: Lines of the user changed' && exit 1)
+
+cp .gitattributes attributes.installed
+gocry -f '../keys%f/key' git install --line-pattern '*.env' --file-pattern '*.key'
+cmp -s .gitattributes attributes.installed || (echo '❌ test [git install]: Installing again changed .gitattributes' && exit 1)
+
+# git expands placeholders in the clean and smudge commands, which a key path must not be taken for
+git config --unset filter.encrypt:line.process
+echo 'PASSWORD=hunter2 ### DIRECTIVE: ENCRYPT' >secrets.env
+git add secrets.env
+git commit -q -m "add secrets"
+git show HEAD:secrets.env | grep -q 'hunter2' && (echo '❌ test [git install]: File was committed in plaintext' && exit 1)
+rm secrets.env && git checkout -q secrets.env
+grep -q 'PASSWORD=hunter2' secrets.env || (echo '❌ test [git install]: File was not decrypted on checkout' && exit 1)
+
+gocry git uninstall
+cmp -s .gitattributes attributes.user || (echo '❌ test [git install]: Uninstalling did not restore .gitattributes' && exit 1)
+git config --local --get-regexp "^filter\.encrypt" >/dev/null && (echo '❌ test [git install]: Filters were not removed' && exit 1)
+
+gocry git uninstall
+cmp -s .gitattributes attributes.user || (echo '❌ test [git install]: Uninstalling again changed .gitattributes' && exit 1)
+
+# An attributes file holding only gocry lines is removed
+rm .gitattributes
+gocry -f '../keys%f/key' git install --line-pattern '*.env'
+gocry git uninstall
+[[ ! -e .gitattributes ]] || (echo '❌ test [git install]: Empty .gitattributes was left behind' && exit 1)
+
+cd ..
+rm -rf repo 'keys%f'
+export GOCRY_KEY=$(cat key)
+
+echo "Starting test [Deterministic, git check]..."
+# check finds staged plaintext in the whole repository, also when run from a subdirectory
+git init -q repo
+cd repo
+git config user.email "gocry@example.com" && git config user.name "gocry"
+
+printf 'sub/*.env filter=encrypt:line\nother/*.key filter=encrypt:file\n' >.gitattributes
+mkdir -p sub/dir other
+echo 'PASSWORD=hunter2 ### DIRECTIVE: ENCRYPT' >sub/secrets.env
+echo 'hunter2' >other/secret.key
+git add .gitattributes sub other 2>/dev/null
+cd sub/dir
+
+rc=0 && GOCRY_QUIET=false gocry git check 2>check.log || rc=$?
+[[ ${rc} -ne 0 ]] || (echo '❌ test [git check]: Staged plaintext was accepted from a subdirectory' && exit 1)
+grep -q '^sub/secrets.env:1: still marked for encryption' check.log || (echo '❌ test [git check]: Line-mode file was not reported' && exit 1)
+grep -q '^other/secret.key: not encrypted' check.log || (echo '❌ test [git check]: Whole file was not reported' && exit 1)
+
+git commit -q --no-verify -m "add plaintext"
+rc=0 && gocry git check --all 2>/dev/null || rc=$?
+[[ ${rc} -ne 0 ]] || (echo '❌ test [git check]: Tracked plaintext was accepted from a subdirectory' && exit 1)
+
+# Encrypted files pass
+gocry -m line encrypt ../secrets.env >secrets.env.enc && mv secrets.env.enc ../secrets.env
+gocry encrypt ../../other/secret.key >secret.key.enc && mv secret.key.enc ../../other/secret.key
+git add ../secrets.env ../../other/secret.key 2>/dev/null
+GOCRY_QUIET=false gocry git check 2>&1 | grep -q 'checked 2 file(s)' || (echo '❌ test [git check]: Encrypted files were not checked' && exit 1)
+git commit -q --no-verify -m "encrypt"
+gocry git check --all || (echo '❌ test [git check]: Encrypted files were refused' && exit 1)
+
+cd ../../..
+rm -rf repo
+
+echo "Starting test [Deterministic, Multiple files]..."
+# Several files, globs and directories are written next to each file, in place or below an output directory
+mkdir -p work/files/sub
+echo "top secret" >top.txt
+echo "a secret" >work/files/a.txt
+echo "b secret" >work/files/sub/b.txt
+echo "another top secret" >work/top.txt
+cd work
+
+# A glob skips the directories it matches, while a directory named explicitly needs --recursive
+gocry encrypt --suffix .enc 'files/*'
+[[ -f files/a.txt.enc ]] || (echo '❌ test [Multiple files]: Globbed file was not encrypted' && exit 1)
+[[ ! -e files/sub/b.txt.enc ]] || (echo '❌ test [Multiple files]: Globbed directory was not skipped' && exit 1)
+
+rc=0 && gocry encrypt --suffix .enc files 2>/dev/null || rc=$?
+[[ ${rc} -ne 0 ]] || (echo '❌ test [Multiple files]: Directory without --recursive was accepted' && exit 1)
+
+gocry encrypt --suffix .enc --recursive files/sub
+[[ -f files/sub/b.txt.enc ]] || (echo '❌ test [Multiple files]: Directory was not walked' && exit 1)
+
+# Files outside the current directory are written to the top level of the output directory
+gocry encrypt --output-dir out ../top.txt files/a.txt
+[[ -f out/top.txt && -f out/files/a.txt ]] || (echo '❌ test [Multiple files]: Wrong output paths' && exit 1)
+[[ $(gocry decrypt out/top.txt) == "top secret" ]] || (echo '❌ test [Multiple files]: Flattened file has the wrong content' && exit 1)
+
+# Two files written to the same path are refused before anything is written
+rc=0 && gocry encrypt --output-dir collision ../top.txt top.txt 2>/dev/null || rc=$?
+[[ ${rc} -ne 0 ]] || (echo '❌ test [Multiple files]: Colliding output paths were accepted' && exit 1)
+[[ ! -e collision ]] || (echo '❌ test [Multiple files]: Files were written despite a collision' && exit 1)
+
+# Files already encrypted are skipped
+gocry encrypt --in-place files/a.txt
+cp files/a.txt a.txt.before
+GOCRY_QUIET=false gocry encrypt --in-place files/a.txt 2>&1 | grep -q 'skipped, already encrypted' || (echo '❌ test [Multiple files]: Encrypted file was not skipped' && exit 1)
+cmp -s files/a.txt a.txt.before || (echo '❌ test [Multiple files]: Encrypted file was encrypted again' && exit 1)
+[[ $(gocry decrypt files/a.txt) == "a secret" ]] || (echo '❌ test [Multiple files]: Encrypted file has the wrong content' && exit 1)
+
+cd ..
+rm -rf work top.txt
+
+echo "Starting test [Deterministic, exec]..."
+# exec injects the decrypted variables and exits with the code of the command
+cat >secrets.env <<'EOF'
+export PASSWORD="hunter 2"
+EOF
+
+gocry -m dotenv encrypt secrets.env >secrets.env.enc
+mv secrets.env.enc secrets.env
+grep -q 'hunter' secrets.env && (echo '❌ test [exec]: File was not encrypted' && exit 1)
+
+[[ $(gocry -m dotenv exec secrets.env -- sh -c 'printf %s "$PASSWORD"') == "hunter 2" ]] || (echo '❌ test [exec]: Variable was not injected' && exit 1)
+
+rc=0 && gocry -m dotenv exec secrets.env -- sh -c 'exit 3' || rc=$?
+[[ ${rc} -eq 3 ]] || (echo "❌ test [exec]: Exit code ${rc} instead of 3" && exit 1)
+
+rc=0 && gocry -m dotenv exec secrets.env -- sh -c 'kill -TERM $$' || rc=$?
+[[ ${rc} -eq 143 ]] || (echo "❌ test [exec]: Exit code ${rc} instead of 143 for a killed command" && exit 1)
+
+rc=0 && gocry -m dotenv exec secrets.env -- ./missing-command 2>/dev/null || rc=$?
+[[ ${rc} -eq 1 ]] || (echo "❌ test [exec]: Exit code ${rc} instead of 1 for a missing command" && exit 1)
+
+# Signals sent to gocry reach the command
+gocry -m dotenv exec secrets.env -- sh -c 'trap "exit 7" TERM; touch started; while :; do sleep 0.1; done' &
+pid=$!
+while [[ ! -f started ]]; do sleep 0.1; done
+kill -TERM ${pid}
+rc=0 && wait ${pid} || rc=$?
+[[ ${rc} -eq 7 ]] || (echo "❌ test [exec]: Exit code ${rc} instead of 7 for a forwarded signal" && exit 1)
+
+rm -f started
+
+rm -f secrets.env
+
+echo "Starting test [Deterministic, edit]..."
+# edit decrypts into a private temporary file, runs the editor and re-encrypts what it left
+cat >editor.sh <<'EOF'
+#!/bin/sh
+printf '%s\n' "$1" >editor.path
+stat -c %a "$1" >editor.perm
+echo "PASSWORD=hunter2" >>"$1"
+EOF
+chmod +x editor.sh
+
+# A new file is created
+EDITOR=./editor.sh gocry edit secrets.env
+grep -q 'hunter2' secrets.env && (echo '❌ test [edit]: New file was not encrypted' && exit 1)
+[[ $(gocry decrypt secrets.env) == "PASSWORD=hunter2" ]] || (echo '❌ test [edit]: New file has the wrong content' && exit 1)
+[[ $(cat editor.perm) == "600" ]] || (echo '❌ test [edit]: Temporary file is not private' && exit 1)
+[[ ! -e $(cat editor.path) ]] || (echo '❌ test [edit]: Temporary file was left behind' && exit 1)
+
+# An existing file is edited
+EDITOR=./editor.sh gocry edit secrets.env
+[[ $(gocry decrypt secrets.env | wc -l) -eq 2 ]] || (echo '❌ test [edit]: Edit was not saved' && exit 1)
+
+# An unchanged file is not rewritten
+cp -p secrets.env secrets.env.before
+EDITOR=true gocry edit secrets.env
+[[ secrets.env -nt secrets.env.before ]] && (echo '❌ test [edit]: Unchanged file was rewritten' && exit 1)
+cmp -s secrets.env secrets.env.before || (echo '❌ test [edit]: Unchanged file changed' && exit 1)
+
+# A failing editor leaves the file as it was
+rc=0 && EDITOR=false gocry edit secrets.env 2>/dev/null || rc=$?
+[[ ${rc} -ne 0 ]] || (echo '❌ test [edit]: Editor failure was not reported' && exit 1)
+cmp -s secrets.env secrets.env.before || (echo '❌ test [edit]: File changed after an editor failure' && exit 1)
+
+rm -f secrets.env secrets.env.before editor.sh editor.path editor.perm
+
 echo "All tests passed! 🎉"
 
 # jscpd:ignore-end
@@ -9,13 +9,6 @@ trap 'echo "🚨🚨 Tests failed! 🚨🚨"' ERR
 
 echo "Running indeterministic tests..."
 
-if ! command -v gogen &>/dev/null; then
-  echo "gogen not found, installing..."
-  mkdir -p ~/.local/bin
-  export PATH="$HOME/.local/bin:$PATH"
-  curl -sSL https://raw.githubusercontent.com/idelchi/gogen/refs/heads/main/install.sh | sh -s -- -v v0.0.0 -d ~/.local/bin
-fi
-
 echo "Installing gocry..."
 go install -buildvcs=false .
 
@@ -27,7 +20,8 @@ cd "${TMPDIR}"
 
 # Generate encryption key
 echo "Generating encryption key with length 32..."
-export GOCRY_KEY=$(gogen key --length 32)
+gocry --quiet keygen --randomized key >/dev/null
+export GOCRY_KEY=$(cat key)
 export GOCRY_QUIET=true
 export GOCRY_DETERMINISTIC=false
 